@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/valyala/fasthttp"
+)
+
+// NewBatchRequestOptions parses a POST request with a JSON array body into
+// a list of GraphQL request options. It returns nil if the request doesn't
+// contain a batch. Operations that can't be decoded result in empty
+// options.
+func NewBatchRequestOptions(r *fasthttp.Request) []*RequestOptions {
+	batch, _ := parseBatchRequestOptions(r)
+	return batch
+}
+
+// parseBatchRequestOptions parses the batch of r like NewBatchRequestOptions
+// and additionally returns the error of the first operation that can't be
+// decoded.
+func parseBatchRequestOptions(r *fasthttp.Request) ([]*RequestOptions, error) {
+	if !r.Header.IsPost() {
+		return nil, nil
+	}
+
+	contentType := strings.Split(string(r.Header.ContentType()), ";")[0]
+	if contentType == ContentTypeGraphQL || contentType == ContentTypeFormURLEncoded {
+		return nil, nil
+	}
+
+	body := bytes.TrimLeft(r.Body(), " \t\r\n")
+	if len(body) == 0 || body[0] != '[' {
+		return nil, nil
+	}
+
+	var operations []json.RawMessage
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return nil, &RequestParseError{Err: err}
+	}
+
+	var firstErr error
+	batch := make([]*RequestOptions, len(operations))
+	for i, operation := range operations {
+		opts, err := parseRequestOptionsJSON(operation)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			opts = &RequestOptions{}
+		}
+		batch[i] = opts
+	}
+	return batch, firstErr
+}
+
+// serveBatch executes the operations of batch, at most batchConcurrency of
+// them at a time, and writes their results as JSON array.
+func (h *Handler) serveBatch(reqCtx *fasthttp.RequestCtx, batch []*RequestOptions) {
+	if len(batch) == 0 {
+		h.writeJSON(reqCtx, fasthttp.StatusBadRequest, &graphql.Result{
+			Errors: gqlerrors.FormatErrors(fmt.Errorf("Must provide at least one operation in a batch.")),
+		})
+		return
+	}
+	if h.maxBatchSize > 0 && len(batch) > h.maxBatchSize {
+		h.writeJSON(reqCtx, fasthttp.StatusBadRequest, &graphql.Result{
+			Errors: gqlerrors.FormatErrors(fmt.Errorf("Batch of %d operations exceeds the maximum of %d.", len(batch), h.maxBatchSize)),
+		})
+		return
+	}
+
+	concurrency := h.batchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	scope := h.scopeOf(reqCtx)
+	if concurrency > 1 {
+		// the request context isn't safe for concurrent use
+		scope.ctx = detachedContext(reqCtx)
+	}
+
+	params := make([]graphql.Params, len(batch))
+	results := make([]*graphql.Result, len(batch))
+	wg := sync.WaitGroup{}
+	for i, opts := range batch {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(i int, opts *RequestOptions) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			// batches are only sent via POST, so the method needs no check
+			params[i], results[i], _ = h.executeIn(scope, opts, nil)
+		}(i, opts)
+	}
+	wg.Wait()
+
+	buff := h.writeJSON(reqCtx, fasthttp.StatusOK, results)
+
+	if h.resultCallbackFn != nil {
+		for i := range results {
+			h.resultCallbackFn(reqCtx, &params[i], results[i], buff)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestBatchRequestOptions(t *testing.T) {
+	req := &fasthttp.Request{}
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.SetBodyString(` [{"query": "{ a }"}, {"query": "query B($v: Int) { b(v: $v) }", "variables": "{\"v\": 1}", "operationName": "B"}]`)
+
+	expected := []*handler.RequestOptions{
+		{Query: "{ a }"},
+		{Query: "query B($v: Int) { b(v: $v) }", Variables: map[string]interface{}{"v": float64(1)}, OperationName: "B"},
+	}
+	if result := handler.NewBatchRequestOptions(req); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+	}
+
+	req.SetBodyString(`{"query": "{ a }"}`)
+	if result := handler.NewBatchRequestOptions(req); result != nil {
+		t.Fatalf("expected no batch, got %v", result)
+	}
+}
+
+func TestHandler_Batch(t *testing.T) {
+	body := `[
+		{"query": "query HeroNameQuery { hero { name } }"},
+		{"query": "query HumanQuery($id: String!) { human(id: $id) { name } }", "variables": {"id": "1000"}}
+	]`
+	expected := []*graphql.Result{
+		{Data: map[string]interface{}{"hero": map[string]interface{}{"name": "R2-D2"}}},
+		{Data: map[string]interface{}{"human": map[string]interface{}{"name": "Luke Skywalker"}}},
+	}
+
+	t.Run("Sequential", func(t *testing.T) {
+		callbacks := 0
+		h := handler.New(&handler.Config{
+			Schema:   &testutil.StarWarsSchema,
+			Batching: true,
+			ResultCallbackFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte) {
+				callbacks++
+			},
+		})
+
+		results := serveBatch(t, h, body)
+		if !reflect.DeepEqual(results, expected) {
+			t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, results))
+		}
+		if callbacks != 2 {
+			t.Fatalf("expected ResultCallbackFn to be called twice, got %d", callbacks)
+		}
+	})
+
+	t.Run("Parallel", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:           &testutil.StarWarsSchema,
+			Batching:         true,
+			BatchConcurrency: 2,
+		})
+
+		results := serveBatch(t, h, body)
+		if !reflect.DeepEqual(results, expected) {
+			t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, results))
+		}
+	})
+
+	t.Run("MaxBatchSize", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:       &testutil.StarWarsSchema,
+			Batching:     true,
+			MaxBatchSize: 1,
+		})
+
+		req := newBatchRequest(body)
+		defer fasthttp.ReleaseRequest(req)
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+
+		if err := serve(h.ServeHTTP, req, resp); err != nil {
+			t.Fatal(err)
+		}
+		if code := resp.StatusCode(); code != http.StatusBadRequest {
+			t.Fatalf("unexpected server response %v", code)
+		}
+		result := decodeResponse(t, resp)
+		if len(result.Errors) != 1 || result.Errors[0].Message != "Batch of 2 operations exceeds the maximum of 1." {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+func TestHandler_Batch_RunsInParallel(t *testing.T) {
+	arrived := sync.WaitGroup{}
+	arrived.Add(2)
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"rendezvous": &graphql.Field{
+					Type: graphql.Boolean,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						arrived.Done()
+						done := make(chan struct{})
+						go func() {
+							arrived.Wait()
+							close(done)
+						}()
+						select {
+						case <-done:
+							return true, nil
+						case <-time.After(time.Second):
+							return false, nil
+						}
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:           &schema,
+		Batching:         true,
+		BatchConcurrency: 2,
+	})
+
+	results := serveBatch(t, h, `[{"query": "{ rendezvous }"}, {"query": "{ rendezvous }"}]`)
+	for _, result := range results {
+		if !reflect.DeepEqual(result.Data, map[string]interface{}{"rendezvous": true}) {
+			t.Fatalf("operations were not executed in parallel: %v", result.Data)
+		}
+	}
+}
+
+func TestHandler_Batch_RootObjectAndContext(t *testing.T) {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"user": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						user, _ := p.Context.Value("user").(string)
+						handler.SetResponseExtension(p.Context, "client", handler.RequestExtensions(p.Context)["client"])
+						return user, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, concurrency := range map[string]int{"Serial": 1, "Parallel": 3} {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			h := handler.New(&handler.Config{
+				Schema:           &schema,
+				Batching:         true,
+				BatchConcurrency: concurrency,
+				RootObjectFn: func(reqCtx *fasthttp.RequestCtx) map[string]interface{} {
+					calls++
+					reqCtx.SetUserValue("user", "test")
+					return map[string]interface{}{}
+				},
+			})
+
+			results := serveBatch(t, h, `[
+				{"query": "{ user }", "extensions": {"client": "a"}},
+				{"query": "{ user }", "extensions": {"client": "b"}},
+				{"query": "{ user }", "extensions": {"client": "c"}}
+			]`)
+			if calls != 1 {
+				t.Fatalf("expected RootObjectFn to be called once, got %d calls", calls)
+			}
+			for i, client := range []string{"a", "b", "c"} {
+				if !reflect.DeepEqual(results[i].Data, map[string]interface{}{"user": "test"}) {
+					t.Fatalf("unexpected data of operation %d: %v", i, results[i].Data)
+				}
+				if results[i].Extensions["client"] != client {
+					t.Fatalf("unexpected extensions of operation %d: %v", i, results[i].Extensions)
+				}
+			}
+		})
+	}
+}
+
+func newBatchRequest(body string) *fasthttp.Request {
+	req := fasthttp.AcquireRequest()
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.URI().SetPath("/graphql")
+	req.SetBodyString(body)
+	return req
+}
+
+func serveBatch(t *testing.T, h *handler.Handler, body string) []*graphql.Result {
+	req := newBatchRequest(body)
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if code := resp.StatusCode(); code != http.StatusOK {
+		t.Fatalf("unexpected server response %v", code)
+	}
+
+	var results []*graphql.Result
+	if err := json.Unmarshal(resp.Body(), &results); err != nil {
+		t.Fatalf("DecodeResponseToType(): %v \n%s", err.Error(), resp.Body())
+	}
+	return results
+}
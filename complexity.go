@@ -0,0 +1,330 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// defaultListArguments are the arguments that multiply the cost of the
+// selections of a list field by default.
+var defaultListArguments = []string{"first", "last", "limit"}
+
+// ComplexityConfig configures how the cost of an operation is estimated.
+// Every field costs DefaultCost unless FieldCosts holds another cost for
+// it. The cost of the selections of a list field is multiplied by the value
+// of its first argument listed in ListArguments.
+type ComplexityConfig struct {
+	// DefaultCost is the cost of a field without an entry in FieldCosts.
+	// Defaults to one.
+	DefaultCost int
+	// ListArguments are the names of arguments that limit the size of a
+	// list. Defaults to first, last and limit.
+	ListArguments []string
+	// FieldCosts holds the costs of fields by "Type.field", see also
+	// ParseCostDirectives.
+	FieldCosts map[string]int
+}
+
+// ParseCostDirectives collects the field costs declared by @cost(weight: N)
+// directives in the given schema definition language document. The result
+// can be used as FieldCosts.
+func ParseCostDirectives(sdl string) (map[string]int, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: sdl})
+	if err != nil {
+		return nil, err
+	}
+
+	costs := map[string]int{}
+	for _, definition := range doc.Definitions {
+		var name *ast.Name
+		var fields []*ast.FieldDefinition
+		switch definition := definition.(type) {
+		case *ast.ObjectDefinition:
+			name, fields = definition.Name, definition.Fields
+		case *ast.InterfaceDefinition:
+			name, fields = definition.Name, definition.Fields
+		case *ast.TypeExtensionDefinition:
+			if definition.Definition == nil {
+				continue
+			}
+			name, fields = definition.Definition.Name, definition.Definition.Fields
+		default:
+			continue
+		}
+
+		for _, field := range fields {
+			for _, directive := range field.Directives {
+				if directive.Name == nil || directive.Name.Value != "cost" {
+					continue
+				}
+				for _, argument := range directive.Arguments {
+					if argument.Name == nil || argument.Name.Value != "weight" {
+						continue
+					}
+					weight, err := costWeight(argument.Value)
+					if err != nil {
+						return nil, fmt.Errorf("cost of %s.%s: %v", name.Value, field.Name.Value, err)
+					}
+					costs[name.Value+"."+field.Name.Value] = weight
+				}
+			}
+		}
+	}
+	return costs, nil
+}
+
+func costWeight(value ast.Value) (int, error) {
+	var raw string
+	switch value := value.(type) {
+	case *ast.IntValue:
+		raw = value.Value
+	case *ast.StringValue:
+		raw = value.Value
+	default:
+		return 0, fmt.Errorf("weight must be an integer")
+	}
+	return strconv.Atoi(raw)
+}
+
+// complexity estimates the cost of the operation of doc that is selected by
+// operationName.
+func (c *ComplexityConfig) complexity(schema *graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) int {
+	operation := selectOperation(doc, operationName)
+	if operation == nil {
+		return 0
+	}
+
+	var root *graphql.Object
+	switch operation.Operation {
+	case ast.OperationTypeQuery:
+		root = schema.QueryType()
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	}
+	if root == nil {
+		return 0
+	}
+
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok && fragment.Name != nil {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	estimator := &complexityEstimator{
+		config:    c,
+		schema:    schema,
+		fragments: fragments,
+		variables: variables,
+	}
+	return estimator.selectionSetCost(operation.SelectionSet, root)
+}
+
+type complexityEstimator struct {
+	config    *ComplexityConfig
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func (e *complexityEstimator) selectionSetCost(selectionSet *ast.SelectionSet, parent graphql.Type) int {
+	if selectionSet == nil {
+		return 0
+	}
+
+	cost := 0
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cost = saturatingAdd(cost, e.fieldCost(selection, parent))
+		case *ast.InlineFragment:
+			cost = saturatingAdd(cost, e.selectionSetCost(selection.SelectionSet, e.typeCondition(selection.TypeCondition, parent)))
+		case *ast.FragmentSpread:
+			if selection.Name == nil {
+				continue
+			}
+			if fragment, ok := e.fragments[selection.Name.Value]; ok {
+				cost = saturatingAdd(cost, e.selectionSetCost(fragment.SelectionSet, e.typeCondition(fragment.TypeCondition, parent)))
+			}
+		}
+	}
+	return cost
+}
+
+func (e *complexityEstimator) fieldCost(field *ast.Field, parent graphql.Type) int {
+	if field.Name == nil || strings.HasPrefix(field.Name.Value, "__") {
+		return 0
+	}
+
+	var definition *graphql.FieldDefinition
+	switch parent := parent.(type) {
+	case *graphql.Object:
+		definition = parent.Fields()[field.Name.Value]
+	case *graphql.Interface:
+		definition = parent.Fields()[field.Name.Value]
+	}
+	if definition == nil {
+		return 0
+	}
+
+	cost, ok := e.config.FieldCosts[parent.Name()+"."+field.Name.Value]
+	if !ok {
+		cost = e.config.defaultCost()
+	}
+
+	childCost := e.selectionSetCost(field.SelectionSet, namedType(definition.Type))
+	return saturatingAdd(cost, saturatingMultiply(childCost, e.multiplier(field, definition)))
+}
+
+// multiplier returns the value of the first list argument of field, or one
+// if field doesn't return a list or has no such argument.
+func (e *complexityEstimator) multiplier(field *ast.Field, definition *graphql.FieldDefinition) int {
+	fieldType := definition.Type
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		fieldType = nonNull.OfType.(graphql.Output)
+	}
+	if _, ok := fieldType.(*graphql.List); !ok {
+		return 1
+	}
+
+	for _, name := range e.config.listArguments() {
+		for _, argument := range field.Arguments {
+			if argument.Name == nil || argument.Name.Value != name {
+				continue
+			}
+			if value, ok := e.intValue(argument.Value); ok {
+				return value
+			}
+		}
+		for _, argument := range definition.Args {
+			if argument.Name() != name {
+				continue
+			}
+			if value, ok := intValue(argument.DefaultValue); ok {
+				return value
+			}
+		}
+	}
+	return 1
+}
+
+func (e *complexityEstimator) intValue(value ast.Value) (int, bool) {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		i, err := strconv.Atoi(value.Value)
+		return i, err == nil && i >= 0
+	case *ast.Variable:
+		if value.Name == nil {
+			return 0, false
+		}
+		return intValue(e.variables[value.Name.Value])
+	}
+	return 0, false
+}
+
+func (e *complexityEstimator) typeCondition(condition *ast.Named, parent graphql.Type) graphql.Type {
+	if condition == nil || condition.Name == nil {
+		return parent
+	}
+	if t := e.schema.Type(condition.Name.Value); t != nil {
+		return t
+	}
+	return parent
+}
+
+func (c *ComplexityConfig) defaultCost() int {
+	if c.DefaultCost == 0 {
+		return 1
+	}
+	return c.DefaultCost
+}
+
+func (c *ComplexityConfig) listArguments() []string {
+	if c.ListArguments == nil {
+		return defaultListArguments
+	}
+	return c.ListArguments
+}
+
+// complexityExceededError reports an operation that is too expensive.
+type complexityExceededError struct {
+	cost int
+	max  int
+}
+
+func (e *complexityExceededError) Error() string {
+	return fmt.Sprintf("Operation has a complexity of %d, which exceeds the maximum complexity of %d.", e.cost, e.max)
+}
+
+// checkComplexity estimates the cost of the selected operation of doc and
+// returns it as response extensions. If the cost exceeds the maximum, a
+// complexityExceededError is returned as well. Nothing is estimated if
+// MaxComplexity is not set.
+func (h *Handler) checkComplexity(doc *ast.Document, operationName string, variables map[string]interface{}) (map[string]interface{}, error) {
+	if h.maxComplexity <= 0 {
+		return nil, nil
+	}
+
+	config := h.complexity
+	if config == nil {
+		config = &ComplexityConfig{}
+	}
+
+	cost := config.complexity(h.Schema, doc, operationName, variables)
+	extensions := map[string]interface{}{
+		"cost": map[string]interface{}{
+			"requestedQueryCost": cost,
+			"maximumAvailable":   h.maxComplexity,
+		},
+	}
+	if cost > h.maxComplexity {
+		return extensions, &complexityExceededError{cost: cost, max: h.maxComplexity}
+	}
+	return extensions, nil
+}
+
+func namedType(t graphql.Type) graphql.Type {
+	for {
+		switch wrapper := t.(type) {
+		case *graphql.NonNull:
+			t = wrapper.OfType
+		case *graphql.List:
+			t = wrapper.OfType
+		default:
+			return t
+		}
+	}
+}
+
+func intValue(value interface{}) (int, bool) {
+	switch value := value.(type) {
+	case int:
+		return value, value >= 0
+	case float64:
+		return int(value), value >= 0 && value <= math.MaxInt32
+	}
+	return 0, false
+}
+
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt32-b {
+		return math.MaxInt32
+	}
+	return a + b
+}
+
+func saturatingMultiply(a, b int) int {
+	if a != 0 && b > math.MaxInt32/a {
+		return math.MaxInt32
+	}
+	return a * b
+}
@@ -0,0 +1,156 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+var itemSchema = func() graphql.Schema {
+	item := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Item",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphql.ID},
+			"name": &graphql.Field{Type: graphql.String},
+		},
+	})
+	item.AddFieldConfig("children", &graphql.Field{
+		Type: graphql.NewList(item),
+		Args: graphql.FieldConfigArgument{
+			"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 5},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"item": &graphql.Field{Type: item},
+				"items": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(item)),
+					Args: graphql.FieldConfigArgument{
+						"first": &graphql.ArgumentConfig{Type: graphql.Int},
+						"limit": &graphql.ArgumentConfig{Type: graphql.Int},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return []interface{}{}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}()
+
+func TestMaxComplexity(t *testing.T) {
+	costs, err := handler.ParseCostDirectives(`type Item { id: ID name: String @cost(weight: 5) }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]int{"Item.name": 5}; !reflect.DeepEqual(costs, expected) {
+		t.Fatalf("expected costs %v, got %v", expected, costs)
+	}
+
+	testCases := map[string]struct {
+		complexity    *handler.ComplexityConfig
+		query         string
+		variables     map[string]interface{}
+		expectedCost  float64
+		expectedError bool
+	}{
+		"ListArgument": {
+			query:        "{ items(first: 10) { id name } }",
+			expectedCost: 21,
+		},
+		"LimitArgument": {
+			query:        "{ items(limit: 3) { id } }",
+			expectedCost: 4,
+		},
+		"Variable": {
+			query:        "query Items($n: Int) { items(first: $n) { id } }",
+			variables:    map[string]interface{}{"n": 20},
+			expectedCost: 21,
+		},
+		"DefaultArgument": {
+			query:        "{ item { children { id } } }",
+			expectedCost: 7,
+		},
+		"Fragment": {
+			query:        "{ items(first: 2) { ...item } } fragment item on Item { id children(first: 2) { id } }",
+			expectedCost: 9,
+		},
+		"FieldCosts": {
+			complexity:   &handler.ComplexityConfig{FieldCosts: costs},
+			query:        "{ items(first: 2) { name } }",
+			expectedCost: 11,
+		},
+		"DefaultCost": {
+			complexity:   &handler.ComplexityConfig{DefaultCost: 2},
+			query:        "{ item { id name } }",
+			expectedCost: 6,
+		},
+		"Exceeded": {
+			query:         "{ items(first: 100) { id } }",
+			expectedCost:  101,
+			expectedError: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := handler.New(&handler.Config{
+				Schema:        &itemSchema,
+				MaxComplexity: 50,
+				Complexity:    testCase.complexity,
+			})
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"query":     testCase.query,
+				"variables": testCase.variables,
+			})
+
+			req := fasthttp.AcquireRequest()
+			req.Header.SetHost("localhost")
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.SetContentType("application/json")
+			req.URI().SetPath("/graphql")
+			req.SetBody(body)
+			defer fasthttp.ReleaseRequest(req)
+
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			result := decodeResponse(t, resp)
+
+			expectedExtensions := map[string]interface{}{
+				"cost": map[string]interface{}{
+					"requestedQueryCost": testCase.expectedCost,
+					"maximumAvailable":   float64(50),
+				},
+			}
+			if !reflect.DeepEqual(result.Extensions, expectedExtensions) {
+				t.Fatalf("expected extensions %v, got %v", expectedExtensions, result.Extensions)
+			}
+			if testCase.expectedError {
+				if result.Data != nil || len(result.Errors) != 1 {
+					t.Fatalf("expected an error, got %v", result)
+				}
+				return
+			}
+			if result.HasErrors() {
+				t.Fatalf("unexpected errors %v", result.Errors)
+			}
+		})
+	}
+}
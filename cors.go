@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	defaultCORSAllowedMethods = []string{fasthttp.MethodGet, fasthttp.MethodPost}
+	defaultCORSAllowedHeaders = append([]string{"Accept", "Authorization", "Content-Type"}, defaultCSRFPreventionHeaders...)
+)
+
+// CORSConfig configures the Cross-Origin Resource Sharing headers of the
+// handler's responses.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins that may access the handler. An
+	// origin may contain one wildcard, like https://*.example.com, a single
+	// "*" allows every origin.
+	AllowedOrigins []string
+	// AllowedMethods lists the methods that may be used. Defaults to GET and
+	// POST.
+	AllowedMethods []string
+	// AllowedHeaders lists the headers that may be sent. A single "*" allows
+	// every header. Defaults to Accept, Authorization, Content-Type and the
+	// default CSRF prevention headers.
+	AllowedHeaders []string
+	// AllowCredentials allows requests with cookies or HTTP authentication.
+	AllowCredentials bool
+	// MaxAge is the time the result of a preflight request may be cached.
+	// Zero omits the header.
+	MaxAge time.Duration
+}
+
+// handle sets the CORS headers of the response to reqCtx. Preflight requests
+// are answered right away, in which case true is returned.
+func (c *CORSConfig) handle(reqCtx *fasthttp.RequestCtx) bool {
+	preflight := reqCtx.IsOptions()
+	if preflight {
+		reqCtx.SetStatusCode(fasthttp.StatusNoContent)
+	}
+
+	origin := string(reqCtx.Request.Header.Peek("Origin"))
+	if origin == "" {
+		return preflight
+	}
+	reqCtx.Response.Header.Add("Vary", "Origin")
+	if !c.allowsOrigin(origin) {
+		return preflight
+	}
+
+	if c.AllowCredentials || !containsString(c.AllowedOrigins, "*") {
+		reqCtx.Response.Header.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		reqCtx.Response.Header.Set("Access-Control-Allow-Origin", "*")
+	}
+	if c.AllowCredentials {
+		reqCtx.Response.Header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		return false
+	}
+
+	reqCtx.Response.Header.Add("Vary", "Access-Control-Request-Method")
+	reqCtx.Response.Header.Add("Vary", "Access-Control-Request-Headers")
+
+	method := strings.ToUpper(string(reqCtx.Request.Header.Peek("Access-Control-Request-Method")))
+	if method == "" || !containsString(c.allowedMethods(), method) {
+		return true
+	}
+	requestHeaders := string(reqCtx.Request.Header.Peek("Access-Control-Request-Headers"))
+	if !c.allowsHeaders(requestHeaders) {
+		return true
+	}
+
+	reqCtx.Response.Header.Set("Access-Control-Allow-Methods", strings.Join(c.allowedMethods(), ", "))
+	if containsString(c.AllowedHeaders, "*") {
+		if requestHeaders != "" {
+			reqCtx.Response.Header.Set("Access-Control-Allow-Headers", requestHeaders)
+		}
+	} else {
+		reqCtx.Response.Header.Set("Access-Control-Allow-Headers", strings.Join(c.allowedHeaders(), ", "))
+	}
+	if c.MaxAge > 0 {
+		reqCtx.Response.Header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+	return true
+}
+
+func (c *CORSConfig) allowsOrigin(origin string) bool {
+	for _, pattern := range c.AllowedOrigins {
+		if matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) allowsHeaders(requestHeaders string) bool {
+	if containsString(c.AllowedHeaders, "*") {
+		return true
+	}
+	for _, header := range strings.Split(requestHeaders, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		allowed := false
+		for _, allowedHeader := range c.allowedHeaders() {
+			if strings.EqualFold(header, allowedHeader) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *CORSConfig) allowedMethods() []string {
+	if c.AllowedMethods == nil {
+		return defaultCORSAllowedMethods
+	}
+	return c.AllowedMethods
+}
+
+func (c *CORSConfig) allowedHeaders() []string {
+	if c.AllowedHeaders == nil {
+		return defaultCORSAllowedHeaders
+	}
+	return c.AllowedHeaders
+}
+
+// matchOrigin returns true if origin matches pattern, which may contain one
+// wildcard matching any non-empty string.
+func matchOrigin(pattern, origin string) bool {
+	pattern, origin = strings.ToLower(pattern), strings.ToLower(origin)
+	index := strings.Index(pattern, "*")
+	if index < 0 {
+		return pattern == origin
+	}
+	prefix, suffix := pattern[:index], pattern[index+1:]
+	return len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
@@ -0,0 +1,163 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestCORS(t *testing.T) {
+	testCases := map[string]struct {
+		cors            *handler.CORSConfig
+		method          string
+		origin          string
+		requestMethod   string
+		requestHeaders  string
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		"Preflight": {
+			cors: &handler.CORSConfig{
+				AllowedOrigins: []string{"https://app.example.com"},
+				MaxAge:         10 * time.Minute,
+			},
+			method:         fasthttp.MethodOptions,
+			origin:         "https://app.example.com",
+			requestMethod:  "POST",
+			requestHeaders: "content-type, graphql-preflight",
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Accept, Authorization, Content-Type, X-Apollo-Operation-Name, Apollo-Require-Preflight, GraphQL-Preflight",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		"PreflightWildcardOrigin": {
+			cors: &handler.CORSConfig{
+				AllowedOrigins:   []string{"https://*.example.com"},
+				AllowedHeaders:   []string{"*"},
+				AllowCredentials: true,
+			},
+			method:         fasthttp.MethodOptions,
+			origin:         "https://app.example.com",
+			requestMethod:  "POST",
+			requestHeaders: "X-Custom",
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Headers":     "X-Custom",
+				"Access-Control-Max-Age":           "",
+			},
+		},
+		"PreflightDisallowedOrigin": {
+			cors: &handler.CORSConfig{
+				AllowedOrigins: []string{"https://*.example.com"},
+			},
+			method:         fasthttp.MethodOptions,
+			origin:         "https://example.com",
+			requestMethod:  "POST",
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+		"PreflightDisallowedMethod": {
+			cors: &handler.CORSConfig{
+				AllowedOrigins: []string{"*"},
+			},
+			method:         fasthttp.MethodOptions,
+			origin:         "https://app.example.com",
+			requestMethod:  "DELETE",
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+		"PreflightDisallowedHeader": {
+			cors: &handler.CORSConfig{
+				AllowedOrigins: []string{"*"},
+			},
+			method:         fasthttp.MethodOptions,
+			origin:         "https://app.example.com",
+			requestMethod:  "POST",
+			requestHeaders: "X-Custom",
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Headers": "",
+			},
+		},
+		"Request": {
+			cors: &handler.CORSConfig{
+				AllowedOrigins: []string{"*"},
+			},
+			method:         fasthttp.MethodPost,
+			origin:         "https://app.example.com",
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "",
+				"Vary":                         "Origin",
+			},
+		},
+		"SameOriginRequest": {
+			cors: &handler.CORSConfig{
+				AllowedOrigins: []string{"*"},
+			},
+			method:         fasthttp.MethodPost,
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := handler.New(&handler.Config{
+				Schema: &statusSchema,
+				CORS:   testCase.cors,
+			})
+
+			req := fasthttp.AcquireRequest()
+			req.Header.SetHost("localhost")
+			req.Header.SetMethod(testCase.method)
+			req.URI().SetPath("/graphql")
+			if testCase.origin != "" {
+				req.Header.Set("Origin", testCase.origin)
+			}
+			if testCase.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", testCase.requestMethod)
+			}
+			if testCase.requestHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", testCase.requestHeaders)
+			}
+			if testCase.method == fasthttp.MethodPost {
+				req.Header.SetContentType("application/json")
+				req.SetBodyString(`{"query": "{ name }"}`)
+			}
+			defer fasthttp.ReleaseRequest(req)
+
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if code := resp.StatusCode(); code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatus, code, resp.Body())
+			}
+			for header, expected := range testCase.expectedHeaders {
+				if value := string(resp.Header.Peek(header)); value != expected {
+					t.Fatalf("expected header %s to be %q, got %q", header, expected, value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// defaultCSRFPreventionHeaders are the headers that mark a request as not
+// being a simple cross-site request by default.
+var defaultCSRFPreventionHeaders = []string{"X-Apollo-Operation-Name", "Apollo-Require-Preflight", "GraphQL-Preflight"}
+
+// simpleContentTypes are the content types browsers send in cross-site
+// requests without a CORS preflight.
+var simpleContentTypes = []string{ContentTypeFormURLEncoded, ContentTypeMultipartFormData, "text/plain"}
+
+// checkCSRF rejects requests that browsers could have sent cross-site
+// without a CORS preflight. Those have neither a content type other than the
+// simple ones nor one of the CSRF prevention headers. Only requests for the
+// IDE page are exempt, as long as they can't trigger mutations.
+func (h *Handler) checkCSRF(r *fasthttp.Request) error {
+	if !h.csrfPrevention || ((r.Header.IsGet() || r.Header.IsHead()) && h.rendersIDE(r)) {
+		return nil
+	}
+
+	contentType := mediaTypeOf(r)
+	if contentType != "" && !containsString(simpleContentTypes, contentType) {
+		return nil
+	}
+
+	headers := h.csrfPreventionHeaders
+	if headers == nil {
+		headers = defaultCSRFPreventionHeaders
+	}
+	for _, header := range headers {
+		if len(r.Header.Peek(header)) > 0 {
+			return nil
+		}
+	}
+
+	return &statusError{
+		status: fasthttp.StatusBadRequest,
+		message: fmt.Sprintf("This operation has been blocked as a potential Cross-Site Request Forgery (CSRF). "+
+			"Please either specify a Content-Type header other than %s or provide a non-empty value for one of the headers %s.",
+			strings.Join(simpleContentTypes, ", "), strings.Join(headers, ", ")),
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestCSRFPrevention(t *testing.T) {
+	testCases := map[string]struct {
+		method         string
+		contentType    string
+		header         string
+		headers        []string
+		accept         string
+		expectedStatus int
+	}{
+		"JSON": {
+			method:         fasthttp.MethodPost,
+			contentType:    "application/json",
+			expectedStatus: http.StatusOK,
+		},
+		"FormURLEncoded": {
+			method:         fasthttp.MethodPost,
+			contentType:    "application/x-www-form-urlencoded",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"FormURLEncodedWithHeader": {
+			method:         fasthttp.MethodPost,
+			contentType:    "application/x-www-form-urlencoded",
+			header:         "GraphQL-Preflight",
+			expectedStatus: http.StatusOK,
+		},
+		"GET": {
+			method:         fasthttp.MethodGet,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"GETWithHeader": {
+			method:         fasthttp.MethodGet,
+			header:         "X-Apollo-Operation-Name",
+			expectedStatus: http.StatusOK,
+		},
+		"CustomHeader": {
+			method:         fasthttp.MethodGet,
+			header:         "X-Requested-With",
+			headers:        []string{"X-Requested-With"},
+			expectedStatus: http.StatusOK,
+		},
+		"ReplacedHeader": {
+			method:         fasthttp.MethodGet,
+			header:         "GraphQL-Preflight",
+			headers:        []string{"X-Requested-With"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		"GraphiQL": {
+			method:         fasthttp.MethodGet,
+			accept:         "text/html",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := handler.New(&handler.Config{
+				Schema:                &statusSchema,
+				GraphiQL:              true,
+				CSRFPrevention:        true,
+				CSRFPreventionHeaders: testCase.headers,
+			})
+
+			req := fasthttp.AcquireRequest()
+			req.Header.SetHost("localhost")
+			req.Header.SetMethod(testCase.method)
+			req.URI().SetPath("/graphql")
+			if testCase.accept != "" {
+				req.Header.Set("Accept", testCase.accept)
+			}
+			if testCase.header != "" {
+				req.Header.Set(testCase.header, "1")
+			}
+			switch {
+			case testCase.method == fasthttp.MethodGet:
+				req.URI().SetQueryString("query={name}")
+			case testCase.contentType == "application/json":
+				req.Header.SetContentType(testCase.contentType)
+				req.SetBodyString(`{"query": "{ name }"}`)
+			default:
+				req.Header.SetContentType(testCase.contentType)
+				req.SetBodyString("query={name}")
+			}
+			defer fasthttp.ReleaseRequest(req)
+
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if code := resp.StatusCode(); code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatus, code, resp.Body())
+			}
+		})
+	}
+}
+
+func TestCSRFPrevention_IDEFormPost(t *testing.T) {
+	mutated := 0
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{Type: graphql.String},
+			},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"mutate": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						mutated++
+						return mutated, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{
+		Schema:         &schema,
+		GraphiQL:       true,
+		CSRFPrevention: true,
+	})
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.SetContentType("application/x-www-form-urlencoded")
+	req.URI().SetPath("/graphql")
+	req.SetBodyString("query=" + url.QueryEscape("mutation { mutate }"))
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if code := resp.StatusCode(); code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, code, resp.Body())
+	}
+	if mutated != 0 {
+		t.Fatalf("expected mutation not to be executed, got %d executions", mutated)
+	}
+}
@@ -0,0 +1,506 @@
+package handler
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/kinds"
+)
+
+// capturesKey is a string, as *fasthttp.RequestCtx only looks up values
+// with string keys.
+const capturesKey = "graphql-fasthttp-handler.captures"
+
+// deferredRootName is the name of the query type of the schema deferred
+// fragments are executed against. Underscores are appended if the schema
+// defines a type of the same name.
+const deferredRootName = "DeferredRoot"
+
+// incrementalSchemas are the schemas the parts of incrementally delivered
+// operations are executed against. They capture the values composite
+// fields resolve to, so deferred fragments can be executed on the objects
+// of the response without resolving the fields leading to them again.
+type incrementalSchemas struct {
+	initial        *graphql.Schema
+	initialTraced  *graphql.Schema
+	deferred       *graphql.Schema
+	deferredTraced *graphql.Schema
+}
+
+// newIncrementalSchemas derives the incremental schemas from schema. The
+// traced ones are only derived if tracing is enabled.
+func newIncrementalSchemas(schema *graphql.Schema, tracing bool) (*incrementalSchemas, error) {
+	deferred, err := deferredSchemaFor(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := &incrementalSchemas{
+		initial:  schemaWith(schema, captureExtension{}),
+		deferred: schemaWith(deferred, captureExtension{}),
+	}
+	if tracing {
+		schemas.initialTraced = schemaWith(schema, captureExtension{}, tracingExtension{})
+		schemas.deferredTraced = schemaWith(deferred, captureExtension{}, tracingExtension{})
+	}
+	return schemas, nil
+}
+
+// schemaWith returns a copy of schema with extensions installed.
+func schemaWith(schema *graphql.Schema, extensions ...graphql.Extension) *graphql.Schema {
+	extended := *schema
+	extended.AddExtensions(extensions...)
+	return &extended
+}
+
+// deferredSchemaFor returns a schema with the types of schema, whose query
+// type has a field named after every object type. The fields resolve to
+// the values of the root object under their response keys, so a single
+// operation executes a deferred fragment on objects of different types.
+func deferredSchemaFor(schema *graphql.Schema) (*graphql.Schema, error) {
+	types := []graphql.Type{}
+	fields := graphql.Fields{}
+	for name, t := range schema.TypeMap() {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		types = append(types, t)
+		if object, ok := t.(*graphql.Object); ok {
+			fields[name] = &graphql.Field{
+				Type:    object,
+				Resolve: resolveDeferredSource,
+			}
+		}
+	}
+
+	name := deferredRootName
+	for schema.Type(name) != nil {
+		name += "_"
+	}
+	derived, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   name,
+			Fields: fields,
+		}),
+		Types:      types,
+		Directives: schema.Directives(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &derived, nil
+}
+
+func resolveDeferredSource(p graphql.ResolveParams) (interface{}, error) {
+	sources, _ := p.Source.(map[string]interface{})
+	key, _ := p.Info.Path.Key.(string)
+	return sources[key], nil
+}
+
+// captures holds the values the composite fields of an incrementally
+// delivered operation resolved to and the types of the objects in its
+// response, both by response path.
+type captures struct {
+	mu      sync.Mutex
+	sources map[string]interface{}
+	types   map[string]*graphql.Object
+}
+
+func newCaptures() *captures {
+	return &captures{
+		sources: map[string]interface{}{},
+		types:   map[string]*graphql.Object{},
+	}
+}
+
+// captureScope relates the response paths of an execution to the ones of
+// the response. Deferred executions select every object they are executed
+// on by an alias, which stands for the path of the object.
+type captureScope struct {
+	captures *captures
+	targets  map[string][]interface{}
+}
+
+// withCaptures returns a context in which the values and types resolved by
+// an execution are recorded in c. targets holds the response paths of the
+// aliases of a deferred execution, it's nil for executions from the root.
+func withCaptures(ctx context.Context, c *captures, targets map[string][]interface{}) context.Context {
+	return withValue(ctx, capturesKey, &captureScope{captures: c, targets: targets})
+}
+
+// responsePath returns the path in the response that path of the execution
+// corresponds to, or false if it's the root of a deferred execution.
+func (s *captureScope) responsePath(path *graphql.ResponsePath) ([]interface{}, bool) {
+	steps := path.AsArray()
+	if s.targets == nil {
+		return steps, true
+	}
+	if len(steps) == 0 {
+		return nil, false
+	}
+	alias, _ := steps[0].(string)
+	target, ok := s.targets[alias]
+	if !ok {
+		return nil, false
+	}
+	return appendPath(target, steps[1:]...), true
+}
+
+// source returns the value the object at path was resolved from. It
+// returns false if the value is unknown, e.g. because it has been returned
+// by a thunk, which would have to be called again.
+func (c *captures) source(path []interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the value of a list item is taken from the value of its list
+	end := len(path)
+	for end > 0 {
+		if _, ok := path[end-1].(int); !ok {
+			break
+		}
+		end--
+	}
+	value, ok := c.sources[pathKey(path[:end])]
+	if !ok {
+		return nil, false
+	}
+	for _, step := range path[end:] {
+		list := reflect.ValueOf(value)
+		if list.Kind() == reflect.Ptr {
+			list = list.Elem()
+		}
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array || step.(int) >= list.Len() {
+			return nil, false
+		}
+		value = list.Index(step.(int)).Interface()
+	}
+	if reflect.ValueOf(value).Kind() == reflect.Func {
+		return nil, false
+	}
+	return value, true
+}
+
+// typeOf returns the type of the object at path, or nil if no field has
+// been resolved on it.
+func (c *captures) typeOf(path []interface{}) *graphql.Object {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.types[pathKey(path)]
+}
+
+func pathKey(path []interface{}) string {
+	var key strings.Builder
+	for _, step := range path {
+		key.WriteByte('/')
+		switch step := step.(type) {
+		case string:
+			key.WriteString(step)
+		case int:
+			key.WriteString(strconv.Itoa(step))
+		}
+	}
+	return key.String()
+}
+
+// captureExtension records the values and types resolved by executions in
+// the captures of their context. It's only installed in the incremental
+// schemas.
+type captureExtension struct{}
+
+var _ graphql.Extension = captureExtension{}
+
+func (captureExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return ctx
+}
+
+func (captureExtension) Name() string {
+	return "incrementalCapture"
+}
+
+func (captureExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (captureExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (captureExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (captureExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	scope, _ := ctx.Value(capturesKey).(*captureScope)
+	if scope == nil {
+		return ctx, func(interface{}, error) {}
+	}
+	c := scope.captures
+
+	object, isObject := info.ParentType.(*graphql.Object)
+	if parent, ok := scope.responsePath(info.Path.Prev); ok && isObject {
+		c.mu.Lock()
+		c.types[pathKey(parent)] = object
+		c.mu.Unlock()
+	}
+	if !graphql.IsCompositeType(graphql.GetNamed(info.ReturnType)) {
+		return ctx, func(interface{}, error) {}
+	}
+	return ctx, func(value interface{}, err error) {
+		if path, ok := scope.responsePath(info.Path); ok {
+			c.mu.Lock()
+			c.sources[pathKey(path)] = value
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (captureExtension) HasResult() bool {
+	return false
+}
+
+func (captureExtension) GetResult(ctx context.Context) interface{} {
+	return nil
+}
+
+// deferredTarget is an object of the response a deferred fragment is
+// executed on.
+type deferredTarget struct {
+	path   []interface{}
+	object *graphql.Object
+	source interface{}
+}
+
+// targets returns the objects of the response fragment applies to. It
+// returns false if the value of any of them is unknown.
+func (e *incrementalExecution) targets(fragment *deferredFragment) ([]deferredTarget, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	targets := []deferredTarget{}
+	known := true
+	e.walkObjects(e.data, appendSelection(fragment.ancestors, fragment.fragment), []interface{}{}, func(path []interface{}) {
+		object := e.captures.typeOf(path)
+		var source interface{} = e.root
+		ok := true
+		if len(path) > 0 {
+			source, ok = e.captures.source(path)
+		}
+		if object == nil || !ok {
+			known = false
+			return
+		}
+		targets = append(targets, deferredTarget{path: path, object: object, source: source})
+	})
+	return targets, known
+}
+
+// applicable returns the fragments of deferred that may apply to an object
+// of the response. The others would only deliver empty parts.
+func (e *incrementalExecution) applicable(deferred []*deferredFragment) []*deferredFragment {
+	result := []*deferredFragment{}
+	for _, fragment := range deferred {
+		if targets, ok := e.targets(fragment); !ok || len(targets) > 0 {
+			result = append(result, fragment)
+		}
+	}
+	return result
+}
+
+// walkObjects calls visit with the path of every object of data that is
+// reached by ancestors. Unlike walkResponse, it skips the objects that
+// ancestors don't apply to because of their type conditions or @skip and
+// @include directives.
+func (e *incrementalExecution) walkObjects(data interface{}, ancestors []ast.Selection, path []interface{}, visit func(path []interface{})) {
+	if list, ok := data.([]interface{}); ok {
+		for index, item := range list {
+			e.walkObjects(item, ancestors, appendPath(path, index), visit)
+		}
+		return
+	}
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if len(ancestors) == 0 {
+		visit(path)
+		return
+	}
+	switch ancestor := ancestors[0].(type) {
+	case *ast.Field:
+		key := responseKey(ancestor)
+		if value, ok := object[key]; ok && e.planner.included(ancestor.Directives) {
+			e.walkObjects(value, ancestors[1:], appendPath(path, key), visit)
+		}
+	case *ast.InlineFragment:
+		if e.planner.included(ancestor.Directives) && e.matches(ancestor.TypeCondition, path) {
+			e.walkObjects(data, ancestors[1:], path, visit)
+		}
+	}
+}
+
+// matches returns true if the type condition applies to the object at path.
+func (e *incrementalExecution) matches(condition *ast.Named, path []interface{}) bool {
+	if condition == nil {
+		return true
+	}
+	object := e.captures.typeOf(path)
+	if object == nil {
+		return false
+	}
+	if condition.Name.Value == object.Name() {
+		return true
+	}
+	schema := e.handler.schema()
+	abstract, ok := schema.Type(condition.Name.Value).(graphql.Abstract)
+	return ok && schema.IsPossibleType(abstract, object)
+}
+
+// executeDeferred executes fragment on targets and returns the result along
+// with an entry for every target the fragment applies to. The data of the
+// result holds the data of the entries at their paths. Every target is
+// selected by an alias, which is replaced by its path in the data, the
+// errors and the trace of the result.
+func (e *incrementalExecution) executeDeferred(ctx context.Context, targets []deferredTarget, fragment *ast.InlineFragment) (*graphql.Result, []incrementalEntry) {
+	sources := map[string]interface{}{}
+	paths := map[string][]interface{}{}
+	selectionSet := &ast.SelectionSet{Kind: kinds.SelectionSet}
+	for index, target := range targets {
+		alias := "_" + strconv.Itoa(index)
+		sources[alias] = target.source
+		paths[alias] = target.path
+		selectionSet.Selections = append(selectionSet.Selections, &ast.Field{
+			Kind:  kinds.Field,
+			Alias: &ast.Name{Kind: kinds.Name, Value: alias},
+			Name:  &ast.Name{Kind: kinds.Name, Value: target.object.Name()},
+			SelectionSet: &ast.SelectionSet{
+				Kind:       kinds.SelectionSet,
+				Selections: []ast.Selection{fragment},
+			},
+		})
+	}
+
+	schema := e.handler.incrementalSchemas.deferred
+	if e.traced {
+		ctx = withTracer(ctx)
+		schema = e.handler.incrementalSchemas.deferredTraced
+	}
+	result := e.execute(withCaptures(ctx, e.captures, paths), schema, sources, selectionSet)
+
+	relocate := func(path []interface{}) []interface{} {
+		if len(path) == 0 {
+			return path
+		}
+		alias, _ := path[0].(string)
+		if target, ok := paths[alias]; ok {
+			return appendPath(target, path[1:]...)
+		}
+		return path
+	}
+	for index := range result.Errors {
+		result.Errors[index].Path = relocate(result.Errors[index].Path)
+	}
+	if tracing, ok := result.Extensions["tracing"].(*tracingResult); ok {
+		// the fields of the root only stand for the targets
+		resolvers := []*resolverTrace{}
+		for _, trace := range tracing.Execution.Resolvers {
+			if len(trace.Path) > 1 {
+				trace.Path = relocate(trace.Path)
+				resolvers = append(resolvers, trace)
+			}
+		}
+		tracing.Execution.Resolvers = resolvers
+	}
+
+	data, _ := result.Data.(map[string]interface{})
+	entries := []incrementalEntry{}
+	var merged interface{} = map[string]interface{}{}
+	for index, target := range targets {
+		object, ok := data["_"+strconv.Itoa(index)].(map[string]interface{})
+		if !ok || len(object) == 0 {
+			continue
+		}
+		entries = append(entries, incrementalEntry{Data: object, Path: target.path})
+		merged = mergeResponse(merged, nestResponse(target.path, object))
+	}
+	result.Data = merged
+	return result, entries
+}
+
+// merge adds data, which is the result of a deferred fragment and rooted
+// like the response, to the data deferred fragments are executed on.
+func (e *incrementalExecution) merge(data interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.data = mergeResponse(e.data, copyResponse(data))
+}
+
+// mergeResponse merges source into target and returns the result. Objects
+// are merged by key and lists by index. Null items of source lists leave
+// the items of target lists as they are. Values of source that target
+// doesn't have are taken over without being copied.
+func mergeResponse(target, source interface{}) interface{} {
+	switch source := source.(type) {
+	case map[string]interface{}:
+		if object, ok := target.(map[string]interface{}); ok {
+			for key, value := range source {
+				object[key] = mergeResponse(object[key], value)
+			}
+			return object
+		}
+	case []interface{}:
+		if list, ok := target.([]interface{}); ok {
+			for len(list) < len(source) {
+				list = append(list, nil)
+			}
+			for index, item := range source {
+				if item != nil {
+					list[index] = mergeResponse(list[index], item)
+				}
+			}
+			return list
+		}
+	}
+	return source
+}
+
+// copyResponse returns a deep copy of the objects and lists of data.
+func copyResponse(data interface{}) interface{} {
+	switch data := data.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(data))
+		for key, value := range data {
+			copied[key] = copyResponse(value)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(data))
+		for index, item := range data {
+			copied[index] = copyResponse(item)
+		}
+		return copied
+	}
+	return data
+}
+
+// nestResponse returns data nested in objects and lists along path. The
+// lists only hold data at the index of path.
+func nestResponse(path []interface{}, data interface{}) interface{} {
+	for index := len(path) - 1; index >= 0; index-- {
+		switch step := path[index].(type) {
+		case string:
+			data = map[string]interface{}{step: data}
+		case int:
+			list := make([]interface{}, step+1)
+			list[step] = data
+			data = list
+		}
+	}
+	return data
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/kinds"
+	"github.com/graphql-go/graphql/language/visitor"
+)
+
+// maxDepthRule returns a validation rule that rejects operations with
+// selections nested deeper than max. Fragment spreads are followed,
+// introspection fields don't count.
+func maxDepthRule(max int) graphql.ValidationRuleFn {
+	return func(context *graphql.ValidationContext) *graphql.ValidationRuleInstance {
+		return &graphql.ValidationRuleInstance{
+			VisitorOpts: &visitor.VisitorOptions{
+				KindFuncMap: map[string]visitor.NamedVisitFuncs{
+					kinds.OperationDefinition: {
+						Kind: func(p visitor.VisitFuncParams) (string, interface{}) {
+							operation, ok := p.Node.(*ast.OperationDefinition)
+							if !ok {
+								return visitor.ActionNoChange, nil
+							}
+							if depth := selectionDepth(context, operation.SelectionSet, map[string]bool{}); depth > max {
+								name := "anonymous"
+								if operation.Name != nil {
+									name = fmt.Sprintf("%q", operation.Name.Value)
+								}
+								context.ReportError(gqlerrors.NewError(
+									fmt.Sprintf("Operation %s has a depth of %d, which exceeds the maximum depth of %d.", name, depth, max),
+									[]ast.Node{operation},
+									"",
+									nil,
+									[]int{},
+									nil,
+								))
+							}
+							return visitor.ActionSkip, nil
+						},
+					},
+				},
+			},
+		}
+	}
+}
+
+// selectionDepth returns the depth of the deepest field in selectionSet.
+// The fragments on the current path are tracked in spreads, so cyclic
+// fragments, which are reported by another rule, end the recursion.
+func selectionDepth(context *graphql.ValidationContext, selectionSet *ast.SelectionSet, spreads map[string]bool) int {
+	if selectionSet == nil {
+		return 0
+	}
+
+	depth := 0
+	for _, selection := range selectionSet.Selections {
+		d := 0
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if selection.Name != nil && strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(context, selection.SelectionSet, spreads)
+		case *ast.InlineFragment:
+			d = selectionDepth(context, selection.SelectionSet, spreads)
+		case *ast.FragmentSpread:
+			if selection.Name == nil || spreads[selection.Name.Value] {
+				continue
+			}
+			fragment := context.Fragment(selection.Name.Value)
+			if fragment == nil {
+				continue
+			}
+			spreads[selection.Name.Value] = true
+			d = selectionDepth(context, fragment.SelectionSet, spreads)
+			delete(spreads, selection.Name.Value)
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
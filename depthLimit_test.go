@@ -0,0 +1,66 @@
+package handler_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestMaxDepth(t *testing.T) {
+	testCases := map[string]struct {
+		query         string
+		expectedError string
+	}{
+		"WithinLimit": {
+			query: "{ hero { friends { name } } }",
+		},
+		"ExceedsLimit": {
+			query:         "query Friends { hero { friends { friends { name } } } }",
+			expectedError: `Operation "Friends" has a depth of 4, which exceeds the maximum depth of 3.`,
+		},
+		"FragmentSpread": {
+			query:         "{ hero { ...friends } } fragment friends on Character { friends { friends { name } } }",
+			expectedError: "Operation anonymous has a depth of 4, which exceeds the maximum depth of 3.",
+		},
+		"InlineFragment": {
+			query:         "{ hero { ... on Droid { friends { friends { name } } } } }",
+			expectedError: "Operation anonymous has a depth of 4, which exceeds the maximum depth of 3.",
+		},
+		"FragmentCycle": {
+			query:         "{ hero { ...a } } fragment a on Character { friends { ...b } } fragment b on Character { friends { ...a } }",
+			expectedError: `Cannot spread fragment "a" within itself via b.`,
+		},
+		"Introspection": {
+			query: "{ __schema { types { fields { type { name } } } } }",
+		},
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		MaxDepth: 3,
+	})
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result := serveDocumentCacheQuery(t, h, testCase.query)
+			if testCase.expectedError == "" {
+				if result.HasErrors() {
+					t.Fatalf("unexpected errors %v", result.Errors)
+				}
+				return
+			}
+			if result.Data != nil {
+				t.Fatalf("expected no data, got %v", result.Data)
+			}
+			for _, err := range result.Errors {
+				if strings.Contains(err.Message, testCase.expectedError) {
+					return
+				}
+			}
+			t.Fatalf("expected error %q, got %v", testCase.expectedError, result.Errors)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// DocumentCacheStats reports the usage of the document cache.
+type DocumentCacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// documentCache holds parsed and validated documents by schema and query
+// text.
+type documentCache struct {
+	hits   uint64
+	misses uint64
+	lru    *lruCache
+}
+
+func newDocumentCache(capacity int) *documentCache {
+	return &documentCache{lru: newLRUCache(capacity)}
+}
+
+// document returns the parsed and validated document of query. Documents
+// that fail to parse or validate are not cached.
+func (c *documentCache) document(ctx context.Context, schema *graphql.Schema, query string, rules []graphql.ValidationRuleFn) (*ast.Document, []gqlerrors.FormattedError) {
+	key := fmt.Sprintf("%p:%s", schema, query)
+	if doc, ok := c.lru.Get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return doc.(*ast.Document), nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	doc, errs := parseDocument(ctx, schema, query, rules)
+	if errs == nil {
+		c.lru.Add(key, doc)
+	}
+	return doc, errs
+}
+
+func (c *documentCache) stats() DocumentCacheStats {
+	return DocumentCacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Size:   c.lru.Len(),
+	}
+}
+
+// DocumentCacheStats returns the hit and miss counters of the document
+// cache. They stay zero if the cache is disabled.
+func (h *Handler) DocumentCacheStats() DocumentCacheStats {
+	if h.documentCache == nil {
+		return DocumentCacheStats{}
+	}
+	return h.documentCache.stats()
+}
+
+// document parses and validates query against the schema and the validation
+// rules of the handler, using the document cache if it's enabled.
+func (h *Handler) document(ctx context.Context, query string) (*ast.Document, []gqlerrors.FormattedError) {
+	if h.documentCache == nil {
+		return parseDocument(ctx, h.schema(), query, h.validationRules)
+	}
+	return h.documentCache.document(ctx, h.schema(), query, h.validationRules)
+}
+
+// parseDocument parses query and validates it against schema using rules,
+// or the specified rules if rules is empty. Both steps are timed if the
+// operation executed in ctx is traced.
+func parseDocument(ctx context.Context, schema *graphql.Schema, query string, rules []graphql.ValidationRuleFn) (*ast.Document, []gqlerrors.FormattedError) {
+	t := tracerFrom(ctx)
+	stopParsing := t.startParsing()
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{
+			Body: []byte(query),
+			Name: "GraphQL request",
+		}),
+	})
+	stopParsing()
+	if err != nil {
+		return nil, gqlerrors.FormatErrors(err)
+	}
+	stopValidation := t.startValidation()
+	validationResult := graphql.ValidateDocument(schema, doc, rules)
+	stopValidation()
+	if !validationResult.IsValid {
+		return nil, validationResult.Errors
+	}
+	return doc, nil
+}
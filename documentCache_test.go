@@ -0,0 +1,86 @@
+package handler_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestDocumentCache(t *testing.T) {
+	query := "query HeroNameQuery { hero { name } }"
+	expected := &graphql.Result{
+		Data: map[string]interface{}{
+			"hero": map[string]interface{}{
+				"name": "R2-D2",
+			},
+		},
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:            &testutil.StarWarsSchema,
+		DocumentCacheSize: 1,
+	})
+
+	for i := 0; i < 3; i++ {
+		if result := serveDocumentCacheQuery(t, h, query); !reflect.DeepEqual(result, expected) {
+			t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+		}
+	}
+	if stats := h.DocumentCacheStats(); stats != (handler.DocumentCacheStats{Hits: 2, Misses: 1, Size: 1}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if result := serveDocumentCacheQuery(t, h, "{ unknown }"); len(result.Errors) != 1 {
+				t.Fatalf("expected one error, got %v", result)
+			}
+		}
+		if stats := h.DocumentCacheStats(); stats != (handler.DocumentCacheStats{Hits: 2, Misses: 3, Size: 1}) {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("Eviction", func(t *testing.T) {
+		serveDocumentCacheQuery(t, h, "{ hero { id } }")
+		serveDocumentCacheQuery(t, h, query)
+		if stats := h.DocumentCacheStats(); stats != (handler.DocumentCacheStats{Hits: 2, Misses: 5, Size: 1}) {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema: &testutil.StarWarsSchema,
+		})
+		if result := serveDocumentCacheQuery(t, h, query); !reflect.DeepEqual(result, expected) {
+			t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+		}
+		if stats := h.DocumentCacheStats(); stats != (handler.DocumentCacheStats{}) {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+	})
+}
+
+func serveDocumentCacheQuery(t *testing.T, h *handler.Handler, query string) *graphql.Result {
+	req := fasthttp.AcquireRequest()
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/graphql")
+	req.URI().SetPath("/graphql")
+	req.SetBodyString(query)
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	return decodeResponse(t, resp)
+}
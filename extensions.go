@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+)
+
+// extensionsKey is a string, as *fasthttp.RequestCtx only looks up values
+// with string keys.
+const extensionsKey = "graphql-fasthttp-handler.extensions"
+
+// operationExtensions holds the extensions an operation was requested with
+// and the ones resolvers add to its response.
+type operationExtensions struct {
+	request map[string]interface{}
+
+	mu       sync.Mutex
+	response map[string]interface{}
+}
+
+// withExtensions returns a context for executing an operation that was
+// requested with the given extensions.
+func withExtensions(ctx context.Context, request map[string]interface{}) (context.Context, *operationExtensions) {
+	extensions := &operationExtensions{request: request}
+	return withValue(ctx, extensionsKey, extensions), extensions
+}
+
+// withValue returns a context that carries value under key. A request
+// context is returned as is with value set as user value, so resolvers can
+// still use it as *fasthttp.RequestCtx.
+func withValue(ctx context.Context, key string, value interface{}) context.Context {
+	if reqCtx, ok := ctx.(*fasthttp.RequestCtx); ok {
+		reqCtx.SetUserValue(key, value)
+		return reqCtx
+	}
+	return context.WithValue(ctx, key, value)
+}
+
+// RequestExtensions returns the extensions sent along with the operation
+// that is executed in ctx, or nil if there are none.
+func RequestExtensions(ctx context.Context) map[string]interface{} {
+	if extensions, ok := ctx.Value(extensionsKey).(*operationExtensions); ok {
+		return extensions.request
+	}
+	return nil
+}
+
+// SetResponseExtension adds value under key to the extensions of the
+// response to the operation that is executed in ctx. Keys set by the
+// handler itself, like cost, take precedence. For subscriptions, the
+// extension is added to the next result. It's safe to be called by
+// concurrently running resolvers and does nothing if ctx doesn't belong to
+// an operation executed by the handler.
+func SetResponseExtension(ctx context.Context, key string, value interface{}) {
+	extensions, ok := ctx.Value(extensionsKey).(*operationExtensions)
+	if !ok {
+		return
+	}
+
+	extensions.mu.Lock()
+	defer extensions.mu.Unlock()
+	if extensions.response == nil {
+		extensions.response = map[string]interface{}{}
+	}
+	extensions.response[key] = value
+}
+
+// apply moves the response extensions set so far into result.
+func (e *operationExtensions) apply(result *graphql.Result) {
+	e.mu.Lock()
+	response := e.response
+	e.response = nil
+	e.mu.Unlock()
+
+	for key, value := range response {
+		if result.Extensions == nil {
+			result.Extensions = map[string]interface{}{}
+		}
+		if _, ok := result.Extensions[key]; !ok {
+			result.Extensions[key] = value
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+var extensionsSchema, _ = graphql.NewSchema(graphql.SchemaConfig{
+	Query: graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"client": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					client, _ := handler.RequestExtensions(p.Context)["client"].(string)
+					handler.SetResponseExtension(p.Context, "greeting", "hello "+client)
+					return client, nil
+				},
+			},
+		},
+	}),
+})
+
+func TestExtensions(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:   &extensionsSchema,
+		Batching: true,
+	})
+
+	cases := map[string]struct {
+		method      string
+		uri         string
+		contentType string
+		body        string
+	}{
+		"JSON": {
+			method:      fasthttp.MethodPost,
+			uri:         "/graphql",
+			contentType: "application/json",
+			body:        `{"query": "{ client }", "extensions": {"client": "test"}}`,
+		},
+		"GET": {
+			method: fasthttp.MethodGet,
+			uri:    "/graphql?query=" + url.QueryEscape("{ client }") + "&extensions=" + url.QueryEscape(`{"client": "test"}`),
+		},
+		"Form": {
+			method:      fasthttp.MethodPost,
+			uri:         "/graphql",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "query=" + url.QueryEscape("{ client }") + "&extensions=" + url.QueryEscape(`{"client": "test"}`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetHost("localhost")
+			req.Header.SetMethod(tc.method)
+			req.SetRequestURI(tc.uri)
+			if tc.contentType != "" {
+				req.Header.SetContentType(tc.contentType)
+				req.SetBodyString(tc.body)
+			}
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if code := resp.StatusCode(); code != http.StatusOK {
+				t.Fatalf("unexpected server response %v", code)
+			}
+			result := decodeResponse(t, resp)
+			if !reflect.DeepEqual(result.Data, map[string]interface{}{"client": "test"}) {
+				t.Fatalf("unexpected data %v", result.Data)
+			}
+			if !reflect.DeepEqual(result.Extensions, map[string]interface{}{"greeting": "hello test"}) {
+				t.Fatalf("unexpected extensions %v", result.Extensions)
+			}
+		})
+	}
+
+	t.Run("Batch", func(t *testing.T) {
+		results := serveBatch(t, h, `[
+			{"query": "{ client }", "extensions": {"client": "a"}},
+			{"query": "{ client }", "extensions": {"client": "b"}}
+		]`)
+		for i, client := range []string{"a", "b"} {
+			if !reflect.DeepEqual(results[i].Extensions, map[string]interface{}{"greeting": "hello " + client}) {
+				t.Fatalf("unexpected extensions of operation %d: %v", i, results[i].Extensions)
+			}
+		}
+	})
+}
+
+func TestExtensions_RequestCtx(t *testing.T) {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"userAgent": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						reqCtx, ok := p.Context.(*fasthttp.RequestCtx)
+						if !ok {
+							return nil, fmt.Errorf("unexpected context %T", p.Context)
+						}
+						handler.SetResponseExtension(p.Context, "client", handler.RequestExtensions(p.Context)["client"])
+						return string(reqCtx.UserAgent()), nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{
+		Schema:  &schema,
+		Tracing: true,
+	})
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.Header.SetUserAgent("test-agent")
+	req.Header.Set(handler.TracingHeader, "1")
+	req.SetRequestURI("/graphql")
+	req.SetBodyString(`{"query": "{ userAgent }", "extensions": {"client": "test"}}`)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	result := decodeResponse(t, resp)
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", result.Errors)
+	}
+	if !reflect.DeepEqual(result.Data, map[string]interface{}{"userAgent": "test-agent"}) {
+		t.Fatalf("unexpected data %v", result.Data)
+	}
+	if result.Extensions["client"] != "test" || result.Extensions["tracing"] == nil {
+		t.Fatalf("unexpected extensions %v", result.Extensions)
+	}
+}
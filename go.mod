@@ -1,10 +1,9 @@
 module github.com/simia-tech/graphql-fasthttp-handler
 
-go 1.13
+go 1.16
 
 require (
-	github.com/gobuffalo/packr/v2 v2.8.0
+	github.com/andybalholm/brotli v1.1.0
 	github.com/graphql-go/graphql v0.7.8
-	github.com/markbates/pkger v0.15.0
 	github.com/valyala/fasthttp v1.6.0
 )
@@ -12,10 +12,11 @@ type playgroundData struct {
 	SubscriptionEndpoint string
 	SetTitle             bool
 	Path                 string
+	StaticVersion        string
 }
 
 // renderPlayground renders the Playground GUI
-func renderPlayground(reqCtx *fasthttp.RequestCtx) {
+func (h *Handler) renderPlayground(reqCtx *fasthttp.RequestCtx) {
 	t := template.New("Playground")
 	t, err := t.Parse(graphcoolPlaygroundTemplate)
 	if err != nil {
@@ -27,10 +28,11 @@ func renderPlayground(reqCtx *fasthttp.RequestCtx) {
 
 	d := playgroundData{
 		PlaygroundVersion:    graphcoolPlaygroundVersion,
-		Endpoint:             Endpoint,
-		SubscriptionEndpoint: SubscriptionEndpoint,
+		Endpoint:             h.endpoint,
+		SubscriptionEndpoint: h.subscriptionEndpoint,
 		SetTitle:             true,
-		Path:                 BasePath,
+		Path:                 h.basePath,
+		StaticVersion:        h.staticVersion,
 	}
 	err = t.ExecuteTemplate(reqCtx, "index", d)
 	if err != nil {
@@ -60,9 +62,9 @@ add "&raw" to the end of the URL within a browser.
   <meta charset=utf-8/>
   <meta name="viewport" content="user-scalable=no, initial-scale=1.0, minimum-scale=1.0, maximum-scale=1.0, minimal-ui">
   <title>GraphQL Playground</title>
-  <link rel="stylesheet" href="{{ .Path }}static/playground/index.css" />
-  <link rel="shortcut icon" href="{{ .Path }}static/playground/favicon.png" />
-  <script src="{{ .Path }}static/playground/middleware.js"></script>
+  <link rel="stylesheet" href="{{ .Path }}static/playground/index.css?v={{ .StaticVersion }}" />
+  <link rel="shortcut icon" href="{{ .Path }}static/playground/favicon.png?v={{ .StaticVersion }}" />
+  <script src="{{ .Path }}static/playground/middleware.js?v={{ .StaticVersion }}"></script>
 </head>
 
 <body>
@@ -94,7 +96,7 @@ add "&raw" to the end of the URL within a browser.
         font-weight: 400;
       }
     </style>
-    <img src='{{ .Path }}static/playground/logo.png' alt=''>
+    <img src='{{ .Path }}static/playground/logo.png?v={{ .StaticVersion }}' alt=''>
     <div class="loading"> Loading
       <span class="title">GraphQL Playground</span>
     </div>
@@ -14,7 +14,7 @@ func TestRenderPlayground(t *testing.T) {
 	cases := map[string]struct {
 		playgroundEnabled    bool
 		accept               string
-		url                  string
+		query                string
 		expectedStatusCode   int
 		expectedContentType  string
 		expectedBodyContains string
@@ -29,7 +29,7 @@ func TestRenderPlayground(t *testing.T) {
 		"doesn't render Playground if turned off": {
 			playgroundEnabled:   false,
 			accept:              "text/html",
-			expectedStatusCode:  http.StatusOK,
+			expectedStatusCode:  http.StatusNotAcceptable,
 			expectedContentType: "application/json; charset=utf-8",
 		},
 		"doesn't render Playground if Content-Type application/json is present": {
@@ -46,7 +46,7 @@ func TestRenderPlayground(t *testing.T) {
 		"doesn't render Playground if 'raw' query is present": {
 			playgroundEnabled:   true,
 			accept:              "text/html",
-			url:                 "?raw",
+			query:               "raw",
 			expectedStatusCode:  http.StatusOK,
 			expectedContentType: "application/json; charset=utf-8",
 		},
@@ -58,7 +58,8 @@ func TestRenderPlayground(t *testing.T) {
 			req.Header.SetHost("localhost")
 			req.Header.SetMethod(fasthttp.MethodGet)
 			req.Header.Set("Accept", tc.accept)
-			req.URI().SetPath(tc.url)
+			req.URI().SetPath("/graphql")
+			req.URI().SetQueryString(tc.query)
 			defer fasthttp.ReleaseRequest(req)
 
 			resp := fasthttp.AcquireResponse()
@@ -88,3 +89,39 @@ func TestRenderPlayground(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderPlayground_PerHandlerConfig(t *testing.T) {
+	for _, prefix := range []string{"/public/", "/admin/"} {
+		h := handler.New(&handler.Config{
+			Schema:               &testutil.StarWarsSchema,
+			Playground:           true,
+			Endpoint:             prefix + "graphql",
+			SubscriptionEndpoint: "ws://localhost" + prefix + "graphql",
+			BasePath:             prefix,
+		})
+
+		req := fasthttp.AcquireRequest()
+		req.Header.SetHost("localhost")
+		req.Header.SetMethod(fasthttp.MethodGet)
+		req.Header.Set("Accept", "text/html")
+		req.URI().SetPath(prefix + "graphql")
+		resp := fasthttp.AcquireResponse()
+
+		if err := serve(h.ServeHTTP, req, resp); err != nil {
+			t.Fatal(err)
+		}
+		body := string(resp.Body())
+		for _, expected := range []string{
+			`href="` + prefix + `static/playground/index.css?v=`,
+			`endpoint: "` + prefix + `graphql"`,
+			`subscriptionEndpoint: "ws://localhost` + prefix + `graphql"`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Fatalf("expected %s to contain %s", body, expected)
+			}
+		}
+
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+}
@@ -16,10 +16,12 @@ type graphiqlData struct {
 	OperationName   string
 	ResultString    string
 	Path            string
+	StaticVersion   string
 }
 
-// renderGraphiQL renders the GraphiQL GUI
-func renderGraphiQL(reqCtx *fasthttp.RequestCtx, params graphql.Params) {
+// renderGraphiQL renders the GraphiQL GUI prefilled with the query of opts
+// and its result, if it has been executed.
+func (h *Handler) renderGraphiQL(reqCtx *fasthttp.RequestCtx, opts *RequestOptions, result *graphql.Result) {
 	t := template.New("GraphiQL")
 	t, err := t.Parse(graphiqlTemplate)
 	if err != nil {
@@ -28,7 +30,7 @@ func renderGraphiQL(reqCtx *fasthttp.RequestCtx, params graphql.Params) {
 	}
 
 	// Create variables string
-	vars, err := json.MarshalIndent(params.VariableValues, "", "  ")
+	vars, err := json.MarshalIndent(opts.Variables, "", "  ")
 	if err != nil {
 		reqCtx.Error(err.Error(), fasthttp.StatusInternalServerError)
 		return
@@ -40,26 +42,25 @@ func renderGraphiQL(reqCtx *fasthttp.RequestCtx, params graphql.Params) {
 
 	// Create result string
 	var resString string
-	if params.RequestString == "" {
-		resString = ""
-	} else {
-		result, err := json.MarshalIndent(graphql.Do(params), "", "  ")
+	if result != nil {
+		resultBytes, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
 			reqCtx.Error(err.Error(), fasthttp.StatusInternalServerError)
 			return
 		}
-		resString = string(result)
+		resString = string(resultBytes)
 	}
 
 	reqCtx.Response.Header.SetContentType("text/html; charset=utf-8")
 
 	d := graphiqlData{
 		GraphiqlVersion: graphiqlVersion,
-		QueryString:     params.RequestString,
+		QueryString:     opts.Query,
 		ResultString:    resString,
 		VariablesString: varsString,
-		OperationName:   params.OperationName,
-		Path:            BasePath,
+		OperationName:   opts.OperationName,
+		Path:            h.basePath,
+		StaticVersion:   h.staticVersion,
 	}
 	err = t.ExecuteTemplate(reqCtx, "index", d)
 	if err != nil {
@@ -102,12 +103,12 @@ add "&raw" to the end of the URL within a browser.
       height: 100vh;
     }
   </style>
-  <link href="{{ .Path }}static/graphiql/graphiql.css" rel="stylesheet" />
-  <script src="{{ .Path }}static/graphiql/es6-promise.auto.min.js"></script>
-  <script src="{{ .Path }}static/graphiql/fetch.min.js"></script>
-  <script src="{{ .Path }}static/graphiql/react.min.js"></script>
-  <script src="{{ .Path }}static/graphiql/react-dom.min.js"></script>
-  <script src="{{ .Path }}static/graphiql/graphiql.min.js"></script>
+  <link href="{{ .Path }}static/graphiql/graphiql.css?v={{ .StaticVersion }}" rel="stylesheet" />
+  <script src="{{ .Path }}static/graphiql/es6-promise.auto.min.js?v={{ .StaticVersion }}"></script>
+  <script src="{{ .Path }}static/graphiql/fetch.min.js?v={{ .StaticVersion }}"></script>
+  <script src="{{ .Path }}static/graphiql/react.min.js?v={{ .StaticVersion }}"></script>
+  <script src="{{ .Path }}static/graphiql/react-dom.min.js?v={{ .StaticVersion }}"></script>
+  <script src="{{ .Path }}static/graphiql/graphiql.min.js?v={{ .StaticVersion }}"></script>
 </head>
 <body>
   <div id="graphiql">Loading...</div>
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/graphql-go/graphql"
 	"github.com/graphql-go/graphql/testutil"
 	handler "github.com/simia-tech/graphql-fasthttp-handler"
 	"github.com/valyala/fasthttp"
@@ -14,7 +15,7 @@ func TestRenderGraphiQL(t *testing.T) {
 	cases := map[string]struct {
 		graphiqlEnabled      bool
 		accept               string
-		url                  string
+		query                string
 		expectedStatusCode   int
 		expectedContentType  string
 		expectedBodyContains string
@@ -29,7 +30,7 @@ func TestRenderGraphiQL(t *testing.T) {
 		"doesn't render graphiQL if turned off": {
 			graphiqlEnabled:     false,
 			accept:              "text/html",
-			expectedStatusCode:  http.StatusOK,
+			expectedStatusCode:  http.StatusNotAcceptable,
 			expectedContentType: "application/json; charset=utf-8",
 		},
 		"doesn't render GraphiQL if Content-Type application/json is present": {
@@ -46,7 +47,7 @@ func TestRenderGraphiQL(t *testing.T) {
 		"doesn't render GraphiQL if 'raw' query is present": {
 			graphiqlEnabled:     true,
 			accept:              "text/html",
-			url:                 "?raw",
+			query:               "raw",
 			expectedStatusCode:  http.StatusOK,
 			expectedContentType: "application/json; charset=utf-8",
 		},
@@ -58,7 +59,8 @@ func TestRenderGraphiQL(t *testing.T) {
 			req.Header.SetHost("localhost")
 			req.Header.SetMethod(fasthttp.MethodGet)
 			req.Header.Set("Accept", tc.accept)
-			req.URI().SetPath(tc.url)
+			req.URI().SetPath("/graphql")
+			req.URI().SetQueryString(tc.query)
 			defer fasthttp.ReleaseRequest(req)
 
 			resp := fasthttp.AcquireResponse()
@@ -87,3 +89,98 @@ func TestRenderGraphiQL(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderGraphiQL_ExecutesOnce(t *testing.T) {
+	resolved := 0
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"count": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						resolved++
+						return resolved, nil
+					},
+				},
+			},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"increment": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						resolved++
+						return resolved, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		config               handler.Config
+		query                string
+		expectedResolved     int
+		expectedBodyContains string
+	}{
+		"Query": {
+			config:               handler.Config{GraphiQL: true},
+			query:                "{ count }",
+			expectedResolved:     1,
+			expectedBodyContains: `\"count\": 1`,
+		},
+		"Mutation": {
+			config:               handler.Config{GraphiQL: true},
+			query:                "mutation { increment }",
+			expectedResolved:     0,
+			expectedBodyContains: "Can only perform a mutation operation from a POST request.",
+		},
+		"SkipResult": {
+			config:           handler.Config{GraphiQL: true, GraphiQLSkipResult: true},
+			query:            "{ count }",
+			expectedResolved: 0,
+		},
+		"Playground": {
+			config:           handler.Config{Playground: true},
+			query:            "{ count }",
+			expectedResolved: 0,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resolved = 0
+			config := tc.config
+			config.Schema = &schema
+			h := handler.New(&config)
+
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetHost("localhost")
+			req.Header.SetMethod(fasthttp.MethodGet)
+			req.Header.Set("Accept", "text/html")
+			req.URI().SetPath("/graphql")
+			req.URI().QueryArgs().Set("query", tc.query)
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if statusCode := resp.StatusCode(); statusCode != http.StatusOK {
+				t.Fatalf("wrong status code, expected %v, got %v", http.StatusOK, statusCode)
+			}
+			if resolved != tc.expectedResolved {
+				t.Fatalf("expected resolvers to run %d times, got %d", tc.expectedResolved, resolved)
+			}
+			if body := string(resp.Body()); !strings.Contains(body, tc.expectedBodyContains) {
+				t.Fatalf("wrong body, expected %s to contain %s", body, tc.expectedBodyContains)
+			}
+		})
+	}
+}
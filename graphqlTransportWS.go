@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// protocolGraphQLTransportWS is the subprotocol name of the GraphQL over
+// WebSocket protocol, see
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const protocolGraphQLTransportWS = "graphql-transport-ws"
+
+const defaultConnectionInitWaitTimeout = 3 * time.Second
+
+const (
+	graphqlTransportWSConnectionInit = "connection_init"
+	graphqlTransportWSConnectionAck  = "connection_ack"
+	graphqlTransportWSPing           = "ping"
+	graphqlTransportWSPong           = "pong"
+	graphqlTransportWSSubscribe      = "subscribe"
+	graphqlTransportWSNext           = "next"
+	graphqlTransportWSError          = "error"
+	graphqlTransportWSComplete       = "complete"
+
+	graphqlTransportWSCloseBadRequest      = 4400
+	graphqlTransportWSCloseUnauthorized    = 4401
+	graphqlTransportWSCloseInitTimeout     = 4408
+	graphqlTransportWSCloseSubscriberExist = 4409
+	graphqlTransportWSCloseTooManyInits    = 4429
+)
+
+// serveGraphQLTransportWS runs the graphql-transport-ws protocol on conn
+// until the connection is closed.
+func (h *Handler) serveGraphQLTransportWS(ctx context.Context, conn *websocketConn, root map[string]interface{}) {
+	operations := &websocketOperations{}
+	defer operations.stopAll()
+
+	timeout := h.connectionInitWaitTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectionInitWaitTimeout
+	}
+	initTimer := time.AfterFunc(timeout, func() {
+		conn.Close(graphqlTransportWSCloseInitTimeout, "Connection initialisation timeout")
+	})
+	defer initTimer.Stop()
+
+	initialised := false
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message websocketMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			conn.Close(graphqlTransportWSCloseBadRequest, "Invalid message received")
+			return
+		}
+
+		switch message.Type {
+		case graphqlTransportWSConnectionInit:
+			if initialised {
+				conn.Close(graphqlTransportWSCloseTooManyInits, "Too many initialisation requests")
+				return
+			}
+			if !initTimer.Stop() {
+				return
+			}
+			initialised = true
+			writeWebSocketMessage(conn, "", graphqlTransportWSConnectionAck, nil)
+
+		case graphqlTransportWSPing:
+			writeWebSocketMessage(conn, "", graphqlTransportWSPong, nil)
+
+		case graphqlTransportWSPong:
+
+		case graphqlTransportWSSubscribe:
+			if !initialised {
+				conn.Close(graphqlTransportWSCloseUnauthorized, "Unauthorized")
+				return
+			}
+			var opts RequestOptions
+			if err := h.decodeWebSocketPayload(message.Payload, &opts); err != nil || message.ID == "" {
+				conn.Close(graphqlTransportWSCloseBadRequest, "Invalid message received")
+				return
+			}
+
+			id := message.ID
+			started := operations.start(ctx, id, func(ctx context.Context) {
+				first, failed := true, false
+				h.streamOperation(ctx, &opts, root, func(result *graphql.Result, payload []byte) bool {
+					if first && result.Data == nil && result.HasErrors() {
+						failed = true
+						writeWebSocketMessage(conn, id, graphqlTransportWSError, result.Errors)
+						return false
+					}
+					first = false
+					return writeWebSocketMessage(conn, id, graphqlTransportWSNext, json.RawMessage(payload)) == nil
+				})
+				if !failed && ctx.Err() == nil {
+					writeWebSocketMessage(conn, id, graphqlTransportWSComplete, nil)
+				}
+			})
+			if !started {
+				conn.Close(graphqlTransportWSCloseSubscriberExist, fmt.Sprintf("Subscriber for %s already exists", id))
+				return
+			}
+
+		case graphqlTransportWSComplete:
+			operations.stop(message.ID)
+
+		default:
+			conn.Close(graphqlTransportWSCloseBadRequest, "Invalid message received")
+			return
+		}
+	}
+}
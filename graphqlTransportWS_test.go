@@ -0,0 +1,472 @@
+package handler_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+var countdownSchema = func() graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return "countdown", nil
+					},
+				},
+			},
+		}),
+		Subscription: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Subscription",
+			Fields: graphql.Fields{
+				"countdown": &graphql.Field{
+					Type: graphql.Int,
+					Args: graphql.FieldConfigArgument{
+						"from": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						from := p.Args["from"].(int)
+						if from < 0 {
+							return nil, fmt.Errorf("cannot count down from %d", from)
+						}
+						events := make(chan interface{})
+						go func() {
+							defer close(events)
+							for i := from; i >= 0; i-- {
+								select {
+								case events <- i:
+								case <-p.Context.Done():
+									return
+								}
+							}
+						}()
+						return events, nil
+					},
+				},
+				"ticker": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						events := make(chan int)
+						go func() {
+							defer close(events)
+							for i := 0; ; i++ {
+								select {
+								case events <- i:
+								case <-p.Context.Done():
+									return
+								}
+								time.Sleep(10 * time.Millisecond)
+							}
+						}()
+						return events, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}()
+
+func TestGraphQLTransportWS(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        &countdownSchema,
+		Subscriptions: true,
+	})
+
+	t.Run("Subscription", func(t *testing.T) {
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-transport-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":   "1",
+			"type": "subscribe",
+			"payload": map[string]interface{}{
+				"query":     "subscription Countdown($from: Int!) { countdown(from: $from) }",
+				"variables": map[string]interface{}{"from": 2},
+			},
+		})
+		for _, value := range []float64{2, 1, 0} {
+			conn.expectJSON(t, map[string]interface{}{
+				"id":      "1",
+				"type":    "next",
+				"payload": map[string]interface{}{"data": map[string]interface{}{"countdown": value}},
+			})
+		}
+		conn.expectJSON(t, map[string]interface{}{"id": "1", "type": "complete"})
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-transport-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":      "q",
+			"type":    "subscribe",
+			"payload": map[string]interface{}{"query": "{ name }"},
+		})
+		conn.expectJSON(t, map[string]interface{}{
+			"id":      "q",
+			"type":    "next",
+			"payload": map[string]interface{}{"data": map[string]interface{}{"name": "countdown"}},
+		})
+		conn.expectJSON(t, map[string]interface{}{"id": "q", "type": "complete"})
+	})
+
+	t.Run("ValidationError", func(t *testing.T) {
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-transport-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":      "1",
+			"type":    "subscribe",
+			"payload": map[string]interface{}{"query": "subscription { unknown }"},
+		})
+		message := conn.readJSON(t)
+		if message["type"] != "error" {
+			t.Fatalf("expected error message, got %v", message)
+		}
+	})
+
+	t.Run("CompleteFromClient", func(t *testing.T) {
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-transport-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":      "1",
+			"type":    "subscribe",
+			"payload": map[string]interface{}{"query": "subscription { ticker }"},
+		})
+		if message := conn.readJSON(t); message["type"] != "next" {
+			t.Fatalf("expected next message, got %v", message)
+		}
+		conn.writeJSON(t, map[string]interface{}{"id": "1", "type": "complete"})
+
+		conn.writeJSON(t, map[string]interface{}{"type": "ping"})
+		for {
+			message := conn.readJSON(t)
+			if message["type"] == "pong" {
+				break
+			}
+			if message["type"] != "next" {
+				t.Fatalf("unexpected message %v", message)
+			}
+		}
+	})
+
+	t.Run("SubscribeBeforeInit", func(t *testing.T) {
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-transport-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":      "1",
+			"type":    "subscribe",
+			"payload": map[string]interface{}{"query": "{ name }"},
+		})
+		conn.expectClose(t, 4401)
+	})
+
+	t.Run("InitTimeout", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:                    &countdownSchema,
+			Subscriptions:             true,
+			ConnectionInitWaitTimeout: 10 * time.Millisecond,
+		})
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-transport-ws")
+		defer conn.Close()
+
+		conn.expectClose(t, 4408)
+	})
+
+	t.Run("PayloadTooDeep", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:        &countdownSchema,
+			Subscriptions: true,
+			MaxJSONDepth:  3,
+		})
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-transport-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":   "1",
+			"type": "subscribe",
+			"payload": map[string]interface{}{
+				"query":     "{ name }",
+				"variables": map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1}}},
+			},
+		})
+		conn.expectClose(t, 4400)
+	})
+
+	t.Run("MessageTooBig", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:        &countdownSchema,
+			Subscriptions: true,
+			MaxBodySize:   64,
+		})
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-transport-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":      "1",
+			"type":    "subscribe",
+			"payload": map[string]interface{}{"query": "{ name name name name name name name name }"},
+		})
+		conn.expectClose(t, 1009)
+	})
+
+	t.Run("UnsupportedSubprotocol", func(t *testing.T) {
+		ln := fasthttputil.NewInmemoryListener()
+		defer ln.Close()
+		go fasthttp.Serve(ln, h.ServeHTTP)
+
+		c, err := ln.Dial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		response := writeUpgradeRequest(t, c, bufio.NewReader(c), "unknown", "")
+		if response.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, response.StatusCode)
+		}
+	})
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	testCases := map[string]struct {
+		config         handler.Config
+		origin         string
+		expectedStatus int
+	}{
+		"NoOrigin": {
+			expectedStatus: http.StatusSwitchingProtocols,
+		},
+		"SameOrigin": {
+			origin:         "http://localhost",
+			expectedStatus: http.StatusSwitchingProtocols,
+		},
+		"CrossOrigin": {
+			origin:         "https://evil.example.com",
+			expectedStatus: http.StatusForbidden,
+		},
+		"CrossOriginAllowedByCORS": {
+			config:         handler.Config{CORS: &handler.CORSConfig{AllowedOrigins: []string{"https://*.example.com"}}},
+			origin:         "https://app.example.com",
+			expectedStatus: http.StatusSwitchingProtocols,
+		},
+		"CrossOriginNotAllowedByCORS": {
+			config:         handler.Config{CORS: &handler.CORSConfig{AllowedOrigins: []string{"https://*.example.com"}}},
+			origin:         "https://evil.example.org",
+			expectedStatus: http.StatusForbidden,
+		},
+		"CheckOrigin": {
+			config: handler.Config{CheckOrigin: func(r *fasthttp.Request) bool {
+				return string(r.Header.Peek("Origin")) == "https://evil.example.com"
+			}},
+			origin:         "https://evil.example.com",
+			expectedStatus: http.StatusSwitchingProtocols,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			config := testCase.config
+			config.Schema = &countdownSchema
+			config.Subscriptions = true
+			h := handler.New(&config)
+
+			ln := fasthttputil.NewInmemoryListener()
+			defer ln.Close()
+			go fasthttp.Serve(ln, h.ServeHTTP)
+
+			c, err := ln.Dial()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			c.SetDeadline(time.Now().Add(5 * time.Second))
+
+			response := writeUpgradeRequest(t, c, bufio.NewReader(c), "graphql-transport-ws", testCase.origin)
+			if response.StatusCode != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, response.StatusCode)
+			}
+		})
+	}
+}
+
+type testWebSocketConn struct {
+	net.Conn
+	ln       *fasthttputil.InmemoryListener
+	reader   *bufio.Reader
+	protocol string
+}
+
+func dialWebSocket(t *testing.T, h fasthttp.RequestHandler, protocols string) *testWebSocketConn {
+	ln := fasthttputil.NewInmemoryListener()
+	go fasthttp.Serve(ln, h)
+
+	c, err := ln.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	reader := bufio.NewReader(c)
+	response := writeUpgradeRequest(t, c, reader, protocols, "")
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d, got %d", http.StatusSwitchingProtocols, response.StatusCode)
+	}
+	if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("wrong accept key %q", accept)
+	}
+
+	return &testWebSocketConn{
+		Conn:     c,
+		ln:       ln,
+		reader:   reader,
+		protocol: response.Header.Get("Sec-WebSocket-Protocol"),
+	}
+}
+
+func writeUpgradeRequest(t *testing.T, c net.Conn, reader *bufio.Reader, protocols, origin string) *http.Response {
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost/graphql", nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	request.Header.Set("Sec-WebSocket-Protocol", protocols)
+	if origin != "" {
+		request.Header.Set("Origin", origin)
+	}
+	if err := request.Write(c); err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func (c *testWebSocketConn) Close() error {
+	c.Conn.Close()
+	return c.ln.Close()
+}
+
+func (c *testWebSocketConn) writeJSON(t *testing.T, value interface{}) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x81}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testWebSocketConn) readFrame(t *testing.T) (byte, []byte) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func (c *testWebSocketConn) readJSON(t *testing.T) map[string]interface{} {
+	opcode, payload := c.readFrame(t)
+	if opcode != 0x1 {
+		t.Fatalf("expected text frame, got opcode %d (%q)", opcode, payload)
+	}
+	var message map[string]interface{}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func (c *testWebSocketConn) expectJSON(t *testing.T, expected map[string]interface{}) {
+	if message := c.readJSON(t); !reflect.DeepEqual(message, expected) {
+		t.Fatalf("expected message %v, got %v", expected, message)
+	}
+}
+
+func (c *testWebSocketConn) expectClose(t *testing.T, code int) {
+	opcode, payload := c.readFrame(t)
+	if opcode != 0x8 {
+		t.Fatalf("expected close frame, got opcode %d (%q)", opcode, payload)
+	}
+	if len(payload) < 2 {
+		t.Fatalf("close frame without code")
+	}
+	if actual := int(binary.BigEndian.Uint16(payload)); actual != code {
+		t.Fatalf("expected close code %d, got %d (%s)", code, actual, payload[2:])
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// protocolGraphQLWS is the subprotocol name of the legacy
+// subscriptions-transport-ws protocol, see
+// https://github.com/apollographql/subscriptions-transport-ws/blob/master/PROTOCOL.md
+const protocolGraphQLWS = "graphql-ws"
+
+const defaultKeepAliveInterval = 12 * time.Second
+
+const (
+	graphqlWSConnectionInit      = "connection_init"
+	graphqlWSConnectionAck       = "connection_ack"
+	graphqlWSConnectionError     = "connection_error"
+	graphqlWSConnectionKeepAlive = "ka"
+	graphqlWSConnectionTerminate = "connection_terminate"
+	graphqlWSStart               = "start"
+	graphqlWSData                = "data"
+	graphqlWSError               = "error"
+	graphqlWSComplete            = "complete"
+	graphqlWSStop                = "stop"
+)
+
+type graphqlWSErrorPayload struct {
+	Message string `json:"message"`
+}
+
+// serveGraphQLWS runs the graphql-ws protocol on conn until the connection
+// is closed.
+func (h *Handler) serveGraphQLWS(ctx context.Context, conn *websocketConn, root map[string]interface{}) {
+	operations := &websocketOperations{}
+	defer operations.stopAll()
+
+	keepAliveDone := make(chan struct{})
+	defer close(keepAliveDone)
+
+	initialised := false
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message websocketMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			writeWebSocketMessage(conn, "", graphqlWSConnectionError, graphqlWSErrorPayload{Message: "Message must be JSON-parseable."})
+			continue
+		}
+
+		switch message.Type {
+		case graphqlWSConnectionInit:
+			if initialised {
+				continue
+			}
+			initialised = true
+			writeWebSocketMessage(conn, "", graphqlWSConnectionAck, nil)
+			writeWebSocketMessage(conn, "", graphqlWSConnectionKeepAlive, nil)
+			go h.keepAliveGraphQLWS(conn, keepAliveDone)
+
+		case graphqlWSConnectionTerminate:
+			conn.Close(websocketCloseNormal, "")
+			return
+
+		case graphqlWSStart:
+			if !initialised {
+				writeWebSocketMessage(conn, message.ID, graphqlWSError, graphqlWSErrorPayload{Message: "Connection has not been initialised."})
+				continue
+			}
+			var opts RequestOptions
+			if err := h.decodeWebSocketPayload(message.Payload, &opts); err != nil {
+				writeWebSocketMessage(conn, message.ID, graphqlWSError, graphqlWSErrorPayload{Message: "Invalid payload."})
+				continue
+			}
+
+			// a start message for a running operation replaces it
+			id := message.ID
+			operations.stop(id)
+			operations.start(ctx, id, func(ctx context.Context) {
+				h.streamOperation(ctx, &opts, root, func(result *graphql.Result, payload []byte) bool {
+					return writeWebSocketMessage(conn, id, graphqlWSData, json.RawMessage(payload)) == nil
+				})
+				if ctx.Err() == nil {
+					writeWebSocketMessage(conn, id, graphqlWSComplete, nil)
+				}
+			})
+
+		case graphqlWSStop:
+			operations.stop(message.ID)
+
+		default:
+			writeWebSocketMessage(conn, message.ID, graphqlWSError, graphqlWSErrorPayload{Message: "Invalid message type."})
+		}
+	}
+}
+
+// keepAliveGraphQLWS sends keep-alive messages until done is closed or the
+// connection fails.
+func (h *Handler) keepAliveGraphQLWS(conn *websocketConn, done <-chan struct{}) {
+	interval := h.keepAliveInterval
+	if interval <= 0 {
+		interval = defaultKeepAliveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := writeWebSocketMessage(conn, "", graphqlWSConnectionKeepAlive, nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestGraphQLWS(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        &countdownSchema,
+		Subscriptions: true,
+	})
+
+	t.Run("Subscription", func(t *testing.T) {
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+		conn.expectJSON(t, map[string]interface{}{"type": "ka"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":   "1",
+			"type": "start",
+			"payload": map[string]interface{}{
+				"query": "subscription { countdown(from: 1) }",
+			},
+		})
+		for _, value := range []float64{1, 0} {
+			conn.expectJSON(t, map[string]interface{}{
+				"id":      "1",
+				"type":    "data",
+				"payload": map[string]interface{}{"data": map[string]interface{}{"countdown": value}},
+			})
+		}
+		conn.expectJSON(t, map[string]interface{}{"id": "1", "type": "complete"})
+	})
+
+	t.Run("ValidationError", func(t *testing.T) {
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+		conn.expectJSON(t, map[string]interface{}{"type": "ka"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":      "1",
+			"type":    "start",
+			"payload": map[string]interface{}{"query": "subscription { unknown }"},
+		})
+		message := conn.readJSON(t)
+		payload, _ := message["payload"].(map[string]interface{})
+		if message["type"] != "data" || payload["errors"] == nil {
+			t.Fatalf("expected data message with errors, got %v", message)
+		}
+		conn.expectJSON(t, map[string]interface{}{"id": "1", "type": "complete"})
+	})
+
+	t.Run("PayloadTooDeep", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:        &countdownSchema,
+			Subscriptions: true,
+			MaxJSONDepth:  3,
+		})
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+		conn.expectJSON(t, map[string]interface{}{"type": "ka"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":   "1",
+			"type": "start",
+			"payload": map[string]interface{}{
+				"query":     "{ name }",
+				"variables": map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1}}},
+			},
+		})
+		conn.expectJSON(t, map[string]interface{}{
+			"id":      "1",
+			"type":    "error",
+			"payload": map[string]interface{}{"message": "Invalid payload."},
+		})
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+		conn.expectJSON(t, map[string]interface{}{"type": "ka"})
+
+		conn.writeJSON(t, map[string]interface{}{
+			"id":      "1",
+			"type":    "start",
+			"payload": map[string]interface{}{"query": "subscription { ticker }"},
+		})
+		if message := conn.readJSON(t); message["type"] != "data" {
+			t.Fatalf("expected data message, got %v", message)
+		}
+		conn.writeJSON(t, map[string]interface{}{"id": "1", "type": "stop"})
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_terminate"})
+		for {
+			opcode, payload := conn.readFrame(t)
+			if opcode == 0x8 {
+				break
+			}
+			if opcode != 0x1 {
+				t.Fatalf("unexpected frame %d (%q)", opcode, payload)
+			}
+		}
+	})
+
+	t.Run("KeepAlive", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:            &countdownSchema,
+			Subscriptions:     true,
+			KeepAliveInterval: 10 * time.Millisecond,
+		})
+		conn := dialWebSocket(t, h.ServeHTTP, "graphql-ws")
+		defer conn.Close()
+
+		conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+		conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+		conn.expectJSON(t, map[string]interface{}{"type": "ka"})
+		conn.expectJSON(t, map[string]interface{}{"type": "ka"})
+	})
+}
+
+func TestWebSocket_ProtocolNegotiation(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        &countdownSchema,
+		Subscriptions: true,
+	})
+
+	conn := dialWebSocket(t, h.ServeHTTP, "graphql-ws, graphql-transport-ws")
+	defer conn.Close()
+	if conn.protocol != "graphql-ws" {
+		t.Fatalf("expected subprotocol graphql-ws, got %s", conn.protocol)
+	}
+}
+
+func TestWebSocket_SharesHandlerCallbacks(t *testing.T) {
+	resolverError := errors.New("resolver error")
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"root": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Info.RootValue.(map[string]interface{})["root"], nil
+					},
+				},
+				"failing": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, resolverError
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callbacks := make(chan *graphql.Result, 1)
+	h := handler.New(&handler.Config{
+		Schema:        &schema,
+		Subscriptions: true,
+		RootObjectFn: func(reqCtx *fasthttp.RequestCtx) map[string]interface{} {
+			return map[string]interface{}{"root": "value"}
+		},
+		FormatErrorFn: func(err error) gqlerrors.FormattedError {
+			return gqlerrors.FormattedError{Message: "formatted: " + err.Error()}
+		},
+		ResultCallbackFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte) {
+			callbacks <- result
+		},
+	})
+
+	for _, protocol := range []string{"graphql-transport-ws", "graphql-ws"} {
+		t.Run(protocol, func(t *testing.T) {
+			conn := dialWebSocket(t, h.ServeHTTP, protocol)
+			defer conn.Close()
+
+			conn.writeJSON(t, map[string]interface{}{"type": "connection_init"})
+			conn.expectJSON(t, map[string]interface{}{"type": "connection_ack"})
+
+			startType, dataType := "subscribe", "next"
+			if protocol == "graphql-ws" {
+				startType, dataType = "start", "data"
+				conn.expectJSON(t, map[string]interface{}{"type": "ka"})
+			}
+			conn.writeJSON(t, map[string]interface{}{
+				"id":      "1",
+				"type":    startType,
+				"payload": map[string]interface{}{"query": "{ root failing }"},
+			})
+			conn.expectJSON(t, map[string]interface{}{
+				"id":   "1",
+				"type": dataType,
+				"payload": map[string]interface{}{
+					"data":   map[string]interface{}{"root": "value", "failing": nil},
+					"errors": []interface{}{map[string]interface{}{"message": "formatted: resolver error", "locations": nil}},
+				},
+			})
+
+			select {
+			case result := <-callbacks:
+				if result.Errors[0].Message != "formatted: resolver error" {
+					t.Fatalf("unexpected result passed to callback: %v", result)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("ResultCallbackFn was not called when it should have been")
+			}
+		})
+	}
+}
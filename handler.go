@@ -3,207 +3,707 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
-	"log"
-	"mime"
-	"net/http"
-	"os"
-	"path/filepath"
+	"fmt"
+	"io/fs"
 	"strings"
+	"time"
 
-	"github.com/gobuffalo/packr/v2"
 	"github.com/graphql-go/graphql"
 	"github.com/valyala/fasthttp"
 
 	"context"
 
 	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
 )
 
 const (
-	ContentTypeJSON           = "application/json"
-	ContentTypeGraphQL        = "application/graphql"
-	ContentTypeFormURLEncoded = "application/x-www-form-urlencoded"
+	ContentTypeJSON              = "application/json"
+	ContentTypeGraphQLResponse   = "application/graphql-response+json"
+	ContentTypeGraphQL           = "application/graphql"
+	ContentTypeFormURLEncoded    = "application/x-www-form-urlencoded"
+	ContentTypeEventStream       = "text/event-stream"
+	ContentTypeMultipartFormData = "multipart/form-data"
+	ContentTypeMultipartMixed    = "multipart/mixed"
 )
 
 var (
-	Endpoint             string = ""
+	// Endpoint is the default of Config.Endpoint.
+	//
+	// Deprecated: Use Config.Endpoint, which can differ between handlers.
+	Endpoint string = ""
+	// SubscriptionEndpoint is the default of Config.SubscriptionEndpoint.
+	//
+	// Deprecated: Use Config.SubscriptionEndpoint, which can differ between
+	// handlers.
 	SubscriptionEndpoint string = ""
-	BasePath             string = ""
+	// BasePath is the default of Config.BasePath.
+	//
+	// Deprecated: Use Config.BasePath, which can differ between handlers.
+	BasePath string = ""
 )
 
 type ResultCallbackFn func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte)
 
 type Handler struct {
-	Schema           *graphql.Schema
-	pretty           bool
-	graphiql         bool
-	playground       bool
-	rootObjectFn     RootObjectFn
-	resultCallbackFn ResultCallbackFn
-	formatErrorFn    func(err error) gqlerrors.FormattedError
+	Schema                     *graphql.Schema
+	pretty                     bool
+	graphiql                   bool
+	graphiqlSkipResult         bool
+	playground                 bool
+	endpoint                   string
+	subscriptionEndpoint       string
+	basePath                   string
+	staticPath                 string
+	staticAssets               map[string]*staticAsset
+	staticVersion              string
+	subscriptions              bool
+	checkOrigin                func(r *fasthttp.Request) bool
+	batching                   bool
+	maxBatchSize               int
+	batchConcurrency           int
+	persistedQueryStore        PersistedQueryStore
+	persistedDocuments         *PersistedDocuments
+	documentCache              *documentCache
+	validationRules            []graphql.ValidationRuleFn
+	maxComplexity              int
+	complexity                 *ComplexityConfig
+	legacyStatusCodes          bool
+	forbidSubscriptionsOverGET bool
+	csrfPrevention             bool
+	csrfPreventionHeaders      []string
+	cors                       *CORSConfig
+	uploads                    bool
+	maxUploadSize              int64
+	maxUploadFiles             int
+	incrementalDelivery        bool
+	incrementalSchema          *graphql.Schema
+	incrementalSchemas         *incrementalSchemas
+	tracingSchema              *graphql.Schema
+	limits                     requestLimits
+	connectionInitWaitTimeout  time.Duration
+	keepAliveInterval          time.Duration
+	rootObjectFn               RootObjectFn
+	resultCallbackFn           ResultCallbackFn
+	formatErrorFn              func(err error) gqlerrors.FormattedError
 }
 
 type RequestOptions struct {
 	Query         string                 `json:"query" url:"query" schema:"query"`
 	Variables     map[string]interface{} `json:"variables" url:"variables" schema:"variables"`
 	OperationName string                 `json:"operationName" url:"operationName" schema:"operationName"`
+	Extensions    map[string]interface{} `json:"extensions" url:"extensions" schema:"extensions"`
+	DocumentID    string                 `json:"documentId" url:"documentId" schema:"documentId"`
 }
 
-// a workaround for getting`variables` as a JSON string
-type requestOptionsCompatibility struct {
-	Query         string `json:"query" url:"query" schema:"query"`
-	Variables     string `json:"variables" url:"variables" schema:"variables"`
-	OperationName string `json:"operationName" url:"operationName" schema:"operationName"`
+// RequestParseError reports GraphQL request parameters that can't be
+// decoded. It's answered with status 400.
+type RequestParseError struct {
+	// Param names the invalid parameter. It's empty if the request body as a
+	// whole is invalid.
+	Param string
+	Err   error
 }
 
-func getFromForm(args *fasthttp.Args) *RequestOptions {
+func (e *RequestParseError) Error() string {
+	if e.Param == "" {
+		return "Invalid request body: " + e.Err.Error()
+	}
+	return fmt.Sprintf("Invalid %s parameter: %v", e.Param, e.Err)
+}
+
+func (e *RequestParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RequestParseError) StatusCode() int {
+	return fasthttp.StatusBadRequest
+}
+
+func getFromForm(args *fasthttp.Args) (*RequestOptions, error) {
 	query := args.Peek("query")
-	if len(query) > 0 {
+	extensionsBytes := args.Peek("extensions")
+	documentID := args.Peek("documentId")
+	if len(query) > 0 || len(extensionsBytes) > 0 || len(documentID) > 0 {
+		var parseErr error
+
 		// get variables map
 		variables := make(map[string]interface{})
-		variablesBytes := args.Peek("variables")
-		json.Unmarshal(variablesBytes, &variables)
+		if err := decodeJSONParam(args.Peek("variables"), &variables); err != nil {
+			variables = make(map[string]interface{})
+			parseErr = &RequestParseError{Param: "variables", Err: err}
+		}
+
+		var extensions map[string]interface{}
+		if err := decodeJSONParam(extensionsBytes, &extensions); err != nil {
+			extensions = nil
+			if parseErr == nil {
+				parseErr = &RequestParseError{Param: "extensions", Err: err}
+			}
+		}
 
 		return &RequestOptions{
 			Query:         string(query),
 			Variables:     variables,
 			OperationName: string(args.Peek("operationName")),
-		}
+			Extensions:    extensions,
+			DocumentID:    string(documentID),
+		}, parseErr
 	}
 
-	return nil
+	return nil, nil
 }
 
-// NewRequestOptions Parses a http.Request into GraphQL request options struct
+// NewRequestOptions Parses a http.Request into GraphQL request options struct.
+// Variables and extensions that can't be decoded are dropped, bodies that
+// can't be decoded result in empty options. Use ParseRequestOptions to get
+// the reason.
 func NewRequestOptions(r *fasthttp.Request) *RequestOptions {
-	if reqOpt := getFromForm(r.URI().QueryArgs()); reqOpt != nil {
-		return reqOpt
+	opts, _ := parseRequestOptions(r)
+	if opts == nil {
+		return &RequestOptions{}
 	}
+	return opts
+}
 
-	if !r.Header.IsPost() {
-		return &RequestOptions{}
+// ParseRequestOptions parses the GraphQL request options of r from its
+// query string or body. Parameters that can't be decoded are reported as
+// *RequestParseError.
+func ParseRequestOptions(r *fasthttp.Request) (*RequestOptions, error) {
+	opts, err := parseRequestOptions(r)
+	if err != nil {
+		return nil, err
 	}
+	return opts, nil
+}
 
-	if r.Body() == nil {
-		return &RequestOptions{}
+// parseRequestOptions parses the GraphQL request options of r. Along with
+// the error about a parameter that can't be decoded, it returns the options
+// without that parameter.
+func parseRequestOptions(r *fasthttp.Request) (*RequestOptions, error) {
+	if reqOpt, err := getFromForm(r.URI().QueryArgs()); reqOpt != nil || err != nil {
+		return reqOpt, err
 	}
 
-	// TODO: improve Content-Type handling
-	contentTypeStr := string(r.Header.ContentType())
-	contentTypeTokens := strings.Split(contentTypeStr, ";")
-	contentType := contentTypeTokens[0]
+	if !r.Header.IsPost() || len(r.Body()) == 0 {
+		return &RequestOptions{}, nil
+	}
 
-	switch contentType {
+	switch mediaTypeOf(r) {
 	case ContentTypeGraphQL:
 		return &RequestOptions{
 			Query: string(r.Body()),
-		}
+		}, nil
 	case ContentTypeFormURLEncoded:
-		// if err := r.ParseForm(); err != nil {
-		// 	return &RequestOptions{}
-		// }
-
-		if reqOpt := getFromForm(r.PostArgs()); reqOpt != nil {
-			return reqOpt
+		if reqOpt, err := getFromForm(r.PostArgs()); reqOpt != nil || err != nil {
+			return reqOpt, err
 		}
-
-		return &RequestOptions{}
-
+		return &RequestOptions{}, nil
 	case ContentTypeJSON:
 		fallthrough
 	default:
-		var opts RequestOptions
-		if err := json.Unmarshal(r.Body(), &opts); err != nil {
-			// Probably `variables` was sent as a string instead of an object.
-			// So, we try to be polite and try to parse that as a JSON string
-			var optsCompatible requestOptionsCompatibility
-			json.Unmarshal(r.Body(), &optsCompatible)
-			json.Unmarshal([]byte(optsCompatible.Variables), &opts.Variables)
+		return parseRequestOptionsJSON(r.Body())
+	}
+}
+
+// parseRequestOptionsJSON decodes the JSON encoded request options data.
+// Variables and extensions may also be sent as JSON encoded strings. Along
+// with the error about one of them, the options without it are returned.
+func parseRequestOptionsJSON(data []byte) (*RequestOptions, error) {
+	var raw struct {
+		Query         string          `json:"query"`
+		Variables     json.RawMessage `json:"variables"`
+		OperationName string          `json:"operationName"`
+		Extensions    json.RawMessage `json:"extensions"`
+		DocumentID    string          `json:"documentId"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, &RequestParseError{Err: err}
+	}
+
+	opts := &RequestOptions{
+		Query:         raw.Query,
+		OperationName: raw.OperationName,
+		DocumentID:    raw.DocumentID,
+	}
+	var parseErr error
+	if err := decodeJSONParam(raw.Variables, &opts.Variables); err != nil {
+		opts.Variables = nil
+		parseErr = &RequestParseError{Param: "variables", Err: err}
+	}
+	if err := decodeJSONParam(raw.Extensions, &opts.Extensions); err != nil {
+		opts.Extensions = nil
+		if parseErr == nil {
+			parseErr = &RequestParseError{Param: "extensions", Err: err}
+		}
+	}
+	return opts, parseErr
+}
+
+// decodeJSONParam decodes the JSON object data into target. Empty data is
+// ignored and a JSON string is decoded as the object it contains.
+func decodeJSONParam(data []byte, target *map[string]interface{}) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	if data[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(data, &encoded); err != nil {
+			return err
+		}
+		if encoded == "" {
+			return nil
 		}
-		return &opts
+		data = []byte(encoded)
 	}
+	return json.Unmarshal(data, target)
 }
 
 // ServeHTTP provides an entrypoint into executing graphQL queries.
 func (h *Handler) ServeHTTP(reqCtx *fasthttp.RequestCtx) {
-	// get query
-	opts := NewRequestOptions(&reqCtx.Request)
+	if h.cors != nil && h.cors.handle(reqCtx) {
+		return
+	}
 
-	// execute graphql query
-	params := graphql.Params{
-		Schema:         *h.Schema,
-		RequestString:  opts.Query,
-		VariableValues: opts.Variables,
-		OperationName:  opts.OperationName,
-		Context:        reqCtx,
+	if h.isStaticRequest(&reqCtx.Request) {
+		h.serveStatic(reqCtx)
+		return
 	}
-	if h.rootObjectFn != nil {
-		params.RootObject = h.rootObjectFn(reqCtx)
+
+	if h.subscriptions && isWebSocketUpgrade(&reqCtx.Request) {
+		h.serveWebSocket(reqCtx)
+		return
 	}
-	result := graphql.Do(params)
 
-	if formatErrorFn := h.formatErrorFn; formatErrorFn != nil && len(result.Errors) > 0 {
-		formatted := make([]gqlerrors.FormattedError, len(result.Errors))
-		for i, formattedError := range result.Errors {
-			formatted[i] = formatErrorFn(formattedError.OriginalError())
+	if !h.legacyStatusCodes {
+		if err := h.checkContentType(&reqCtx.Request); err != nil {
+			h.writeJSON(reqCtx, statusCodeOf(err), errorResult(err))
+			return
 		}
-		result.Errors = formatted
 	}
 
-	if h.graphiql {
-		acceptHeader := string(reqCtx.Request.Header.Peek("Accept"))
-		raw := reqCtx.Request.URI().QueryArgs().Has("raw")
-		if !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html") {
-			renderGraphiQL(reqCtx, params)
+	if err := h.checkCSRF(&reqCtx.Request); err != nil {
+		h.writeJSON(reqCtx, statusCodeOf(err), errorResult(err))
+		return
+	}
+
+	if !h.acceptable(&reqCtx.Request) {
+		h.writeJSON(reqCtx, statusCodeOf(errNotAcceptable), errorResult(errNotAcceptable))
+		return
+	}
+
+	if err := h.limits.checkBody(&reqCtx.Request); err != nil {
+		h.writeJSON(reqCtx, statusCodeOf(err), errorResult(err))
+		return
+	}
+
+	var opts *RequestOptions
+	if h.uploads && isMultipartRequest(&reqCtx.Request) {
+		operations, batch, err := h.newMultipartRequestOptions(&reqCtx.Request)
+		if err != nil {
+			h.writeJSON(reqCtx, statusCodeOf(err), errorResult(err))
+			return
+		}
+		if batch {
+			if !h.batching {
+				h.writeJSON(reqCtx, statusCodeOf(errBatchingDisabled), errorResult(errBatchingDisabled))
+				return
+			}
+			h.serveBatch(reqCtx, operations)
+			return
+		}
+		opts = operations[0]
+	} else if h.batching {
+		batch, err := parseBatchRequestOptions(&reqCtx.Request)
+		if err != nil {
+			h.writeJSON(reqCtx, statusCodeOf(err), errorResult(err))
+			return
+		}
+		if batch != nil {
+			h.serveBatch(reqCtx, batch)
 			return
 		}
 	}
 
-	if h.playground {
-		acceptHeader := string(reqCtx.Request.Header.Peek("Accept"))
-		raw := reqCtx.Request.URI().QueryArgs().Has("raw")
-		if !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html") {
-			renderPlayground(reqCtx)
+	// get query
+	if opts == nil {
+		var err error
+		if opts, err = ParseRequestOptions(&reqCtx.Request); err != nil {
+			h.writeJSON(reqCtx, statusCodeOf(err), errorResult(err))
 			return
 		}
 	}
 
-	if bytes.Equal(reqCtx.Request.Header.Method(), []byte(fasthttp.MethodGet)) && bytes.Contains(reqCtx.URI().Path(), []byte("/static/")) {
-		serveStatic(reqCtx)
+	if h.subscriptions && acceptsEventStream(&reqCtx.Request) {
+		h.serveEventStream(reqCtx, opts)
 		return
 	}
 
+	if h.incrementalDelivery && acceptsMultipartMixed(&reqCtx.Request) && h.serveIncremental(reqCtx, opts) {
+		return
+	}
+
+	if h.rendersIDE(&reqCtx.Request) {
+		h.renderIDE(reqCtx, opts)
+		return
+	}
+
+	// execute graphql query
+	params, result, status := h.execute(reqCtx, opts)
+
+	buff := h.writeResult(reqCtx, status, result)
+
+	if h.resultCallbackFn != nil {
+		h.resultCallbackFn(reqCtx, &params, result, buff)
+	}
+}
+
+// operationScope holds what the execution of an operation needs from its
+// request. It's captured up front, so operations of a batch can be executed
+// in parallel without accessing the request context.
+type operationScope struct {
+	ctx    context.Context
+	root   map[string]interface{}
+	traced bool
+}
+
+// scopeOf returns the scope of operations requested by reqCtx.
+func (h *Handler) scopeOf(reqCtx *fasthttp.RequestCtx) operationScope {
+	scope := operationScope{
+		ctx:    reqCtx,
+		traced: h.traced(&reqCtx.Request),
+	}
+	if h.rootObjectFn != nil {
+		scope.root = h.rootObjectFn(reqCtx)
+	}
+	return scope
+}
+
+// traced returns true if the operations requested by r are traced.
+func (h *Handler) traced(r *fasthttp.Request) bool {
+	return h.tracingSchema != nil && r.Header.Peek(TracingHeader) != nil
+}
+
+// execute runs the operation described by opts in the scope of reqCtx. The
+// returned status code is the one of the HTTP response.
+func (h *Handler) execute(reqCtx *fasthttp.RequestCtx, opts *RequestOptions) (graphql.Params, *graphql.Result, int) {
+	return h.executeIn(h.scopeOf(reqCtx), opts, func(doc *ast.Document) error {
+		return h.checkMethod(reqCtx, doc, opts.OperationName)
+	})
+}
+
+// executeIn runs the operation described by opts in scope. checkMethod is
+// called with the document once the query is resolved, parsed and
+// validated, unless it's nil.
+func (h *Handler) executeIn(scope operationScope, opts *RequestOptions, checkMethod func(doc *ast.Document) error) (graphql.Params, *graphql.Result, int) {
+	if checkMethod == nil {
+		checkMethod = func(*ast.Document) error { return nil }
+	}
+	ctx, extensions := withExtensions(scope.ctx, opts.Extensions)
+	if scope.traced {
+		ctx = withTracer(ctx)
+	}
+	params := graphql.Params{
+		Schema:         *h.schema(),
+		VariableValues: opts.Variables,
+		OperationName:  opts.OperationName,
+		Context:        ctx,
+	}
+
+	var result *graphql.Result
+	var status int
+	if err := h.limits.checkQuery(opts); err != nil {
+		result = errorResult(err)
+		status = statusCodeOf(err)
+	} else if err := h.resolveQuery(ctx, opts); err != nil {
+		result = errorResult(err)
+		status = statusCodeOf(err)
+	} else if doc, errs := h.document(ctx, opts.Query); errs != nil {
+		result = &graphql.Result{Errors: errs}
+		status = resultStatusCode(result)
+	} else if err := checkMethod(doc); err != nil {
+		result = errorResult(err)
+		status = statusCodeOf(err)
+	} else {
+		params.RequestString = opts.Query
+		params.RootObject = scope.root
+		result = h.do(params, doc)
+		extensions.apply(result)
+		status = resultStatusCode(result)
+	}
+	h.formatErrors(result)
+	return params, result, status
+}
+
+// do executes params on doc, which must already be validated, and rejects
+// too complex operations.
+func (h *Handler) do(params graphql.Params, doc *ast.Document) *graphql.Result {
+	if tracerFrom(params.Context) != nil {
+		params.Schema = *h.tracingSchema
+	}
+
+	extensions, err := h.checkComplexity(doc, params.OperationName, params.VariableValues)
+	if err != nil {
+		result := errorResult(err)
+		result.Extensions = extensions
+		return result
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        params.Schema,
+		Root:          params.RootObject,
+		AST:           doc,
+		OperationName: params.OperationName,
+		Args:          params.VariableValues,
+		Context:       params.Context,
+	})
+	for key, value := range extensions {
+		if result.Extensions == nil {
+			result.Extensions = map[string]interface{}{}
+		}
+		result.Extensions[key] = value
+	}
+	return result
+}
+
+// writeResult writes the result of the operation of a well-formed request
+// like writeJSON. As recommended by the GraphQL over HTTP specification,
+// application/json responses use 200 even if the operation failed to parse
+// or validate. Only refused methods are still reported with 405.
+func (h *Handler) writeResult(reqCtx *fasthttp.RequestCtx, status int, result *graphql.Result) []byte {
+	if mediaType, ok := negotiateMediaType(string(reqCtx.Request.Header.Peek("Accept"))); (!ok || mediaType == ContentTypeJSON) && status != fasthttp.StatusMethodNotAllowed {
+		status = fasthttp.StatusOK
+	}
+	return h.writeJSON(reqCtx, status, result)
+}
+
+// writeJSON writes value as JSON response body with the given status code
+// and returns the written bytes. The content type is negotiated from the
+// Accept header of the request. Legacy status codes only apply to
+// application/json responses, as application/graphql-response+json requires
+// proper ones. Refused methods are always reported with 405.
+func (h *Handler) writeJSON(reqCtx *fasthttp.RequestCtx, status int, value interface{}) []byte {
+	mediaType, ok := negotiateMediaType(string(reqCtx.Request.Header.Peek("Accept")))
+	if !ok {
+		mediaType = ContentTypeJSON
+	}
+	if h.legacyStatusCodes && mediaType == ContentTypeJSON && status != fasthttp.StatusMethodNotAllowed {
+		status = fasthttp.StatusOK
+	}
+
 	// use proper JSON Header
-	reqCtx.Response.Header.SetContentType("application/json; charset=utf-8")
+	reqCtx.Response.Header.SetContentType(mediaType + "; charset=utf-8")
 
 	var buff []byte
 	if h.pretty {
-		reqCtx.SetStatusCode(fasthttp.StatusOK)
-		buff, _ = json.MarshalIndent(result, "", "\t")
+		reqCtx.SetStatusCode(status)
+		buff, _ = json.MarshalIndent(value, "", "\t")
 		reqCtx.Write(buff)
 	} else {
-		reqCtx.SetStatusCode(fasthttp.StatusOK)
-		buff, _ = json.Marshal(result)
+		reqCtx.SetStatusCode(status)
+		buff, _ = json.Marshal(value)
 		reqCtx.Write(buff)
 	}
+	return buff
+}
 
-	if h.resultCallbackFn != nil {
-		h.resultCallbackFn(reqCtx, &params, result, buff)
+// formatErrors applies the configured FormatErrorFn to the errors of result.
+func (h *Handler) formatErrors(result *graphql.Result) {
+	if formatErrorFn := h.formatErrorFn; formatErrorFn != nil && len(result.Errors) > 0 {
+		formatted := make([]gqlerrors.FormattedError, len(result.Errors))
+		for i, formattedError := range result.Errors {
+			formatted[i] = formatErrorFn(formattedError.OriginalError())
+		}
+		result.Errors = formatted
+	}
+}
+
+// errorResult returns a result that reports err. Extensions provided by err
+// are kept in the formatted error.
+func errorResult(err error) *graphql.Result {
+	return &graphql.Result{
+		Errors: []gqlerrors.FormattedError{
+			gqlerrors.FormatError(gqlerrors.NewError(err.Error(), nil, "", nil, []int{}, err)),
+		},
 	}
 }
 
+// rendersIDE returns true if r is a browser request for GraphiQL or
+// Playground.
+func (h *Handler) rendersIDE(r *fasthttp.Request) bool {
+	if !h.graphiql && !h.playground {
+		return false
+	}
+	acceptHeader := string(r.Header.Peek("Accept"))
+	raw := r.URI().QueryArgs().Has("raw")
+	return !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html")
+}
+
+// renderIDE renders GraphiQL or Playground. GraphiQL gets prefilled with
+// the result of the query of the request, unless GraphiQLSkipResult is set.
+func (h *Handler) renderIDE(reqCtx *fasthttp.RequestCtx, opts *RequestOptions) {
+	if !h.graphiql {
+		h.renderPlayground(reqCtx)
+		return
+	}
+
+	var result *graphql.Result
+	if !h.graphiqlSkipResult && opts.Query != "" {
+		_, result, _ = h.execute(reqCtx, opts)
+	}
+	h.renderGraphiQL(reqCtx, opts, result)
+}
+
 // RootObjectFn allows a user to generate a RootObject per request
 type RootObjectFn func(reqCtx *fasthttp.RequestCtx) map[string]interface{}
 
 type Config struct {
-	Schema           *graphql.Schema
-	Pretty           bool
-	GraphiQL         bool
-	Playground       bool
-	RootObjectFn     RootObjectFn
-	ResultCallbackFn ResultCallbackFn
-	FormatErrorFn    func(err error) gqlerrors.FormattedError
+	Schema   *graphql.Schema
+	Pretty   bool
+	GraphiQL bool
+	// GraphiQLSkipResult renders GraphiQL without executing the query of the
+	// request, so the result only shows up once the query is run in the IDE.
+	// By default, GraphiQL is prefilled with the result.
+	GraphiQLSkipResult bool
+	Playground         bool
+	// Endpoint is the URL Playground sends operations to. Defaults to the
+	// package level Endpoint.
+	Endpoint string
+	// SubscriptionEndpoint is the URL Playground opens WebSocket connections
+	// to. Defaults to the package level SubscriptionEndpoint.
+	SubscriptionEndpoint string
+	// BasePath is prepended to the paths of the static assets of GraphiQL and
+	// Playground, which are served below BasePath + "static/". Paths are
+	// made absolute, so it defaults to "/" if the package level BasePath is
+	// empty.
+	BasePath string
+	// StaticFS replaces the static assets of GraphiQL and Playground, which
+	// default to StaticFS(). It must provide the same paths.
+	StaticFS fs.FS
+	// Subscriptions enables serving operations over WebSocket connections
+	// and as Server-Sent Events to requests accepting text/event-stream.
+	// The graphql-transport-ws and the legacy graphql-ws protocol are
+	// negotiated via the Sec-WebSocket-Protocol header.
+	Subscriptions bool
+	// CheckOrigin decides whether a WebSocket upgrade request is accepted.
+	// Browsers send cookies along with cross-site WebSocket handshakes and
+	// apply neither CORS nor CSRF prevention to them, so by default only
+	// requests without Origin header, from the handler's own origin or from
+	// an origin allowed by CORS are accepted.
+	CheckOrigin func(r *fasthttp.Request) bool
+	// Batching enables executing JSON arrays of operations sent in a single
+	// request. The results are returned as an array in the same order.
+	Batching bool
+	// MaxBatchSize limits the number of operations of a batch. Zero means no
+	// limit.
+	MaxBatchSize int
+	// BatchConcurrency is the number of operations of a batch that are
+	// executed in parallel. Zero or one executes them one after another.
+	// RootObjectFn is called once per batch. Operations executed in parallel
+	// don't get the request context, which isn't safe for concurrent use,
+	// but a snapshot of its user values.
+	BatchConcurrency int
+	// PersistedQueryStore enables automatic persisted queries. Queries sent
+	// along with their SHA-256 hash are registered in the store, so later
+	// requests only need to send the hash.
+	PersistedQueryStore PersistedQueryStore
+	// PersistedDocuments restricts execution to the documents of an
+	// allowlist. Documents are requested by documentId or by the hash of an
+	// automatic persisted query, other query texts are rejected. If set,
+	// PersistedQueryStore is not used.
+	PersistedDocuments *PersistedDocuments
+	// DocumentCacheSize enables caching up to the given number of parsed and
+	// validated documents, so repeated queries skip both steps. Zero
+	// disables the cache.
+	DocumentCacheSize int
+	// MaxDepth rejects operations with fields nested deeper than the given
+	// depth before they are executed. Fragment spreads are followed,
+	// introspection fields are not counted. Zero means no limit.
+	MaxDepth int
+	// MaxComplexity rejects operations whose estimated cost exceeds the given
+	// value before they are executed. The cost is reported in the cost
+	// extension of the response. Zero means no limit.
+	MaxComplexity int
+	// Complexity configures the cost estimation of MaxComplexity. If nil,
+	// every field costs one and lists are multiplied by their first, last or
+	// limit argument.
+	Complexity *ComplexityConfig
+	// LegacyStatusCodes makes the handler respond with status 200 to every
+	// application/json request, like earlier versions did. By default,
+	// bodies that can't be decoded are answered with 400, bodies of
+	// unsupported content types with 415 and requests that accept neither
+	// application/graphql-response+json nor application/json with 406.
+	// Operations that fail to parse or validate are only answered with 400
+	// if the response is of type application/graphql-response+json, which
+	// always uses proper status codes.
+	LegacyStatusCodes bool
+	// ForbidSubscriptionsOverGET refuses subscriptions sent via GET, like
+	// mutations, which are always refused with 405 so they can't be
+	// triggered by cross-site links.
+	ForbidSubscriptionsOverGET bool
+	// CSRFPrevention rejects requests that browsers may send cross-site
+	// without a CORS preflight. Requests must either have a content type
+	// other than application/x-www-form-urlencoded, multipart/form-data and
+	// text/plain, or one of the CSRFPreventionHeaders.
+	CSRFPrevention bool
+	// CSRFPreventionHeaders are the headers accepted by CSRFPrevention.
+	// Defaults to X-Apollo-Operation-Name, Apollo-Require-Preflight and
+	// GraphQL-Preflight.
+	CSRFPreventionHeaders []string
+	// CORS enables Cross-Origin Resource Sharing. Preflight requests are
+	// answered by the handler.
+	CORS *CORSConfig
+	// Uploads enables multipart/form-data requests following the GraphQL
+	// multipart request specification. Files are passed to arguments of type
+	// UploadScalar.
+	Uploads bool
+	// MaxUploadSize limits the size of each uploaded file in bytes. Zero
+	// means no limit besides the server's maximum request body size.
+	MaxUploadSize int64
+	// MaxUploadFiles limits the number of files of a request. Zero means no
+	// limit.
+	MaxUploadFiles int
+	// IncrementalDelivery enables the @defer and @stream directives. Queries
+	// using them are answered as multipart/mixed if the request accepts it,
+	// other requests get all results in a single response.
+	IncrementalDelivery bool
+	// Tracing enables reporting the timings of parsing, validation and every
+	// resolver in the tracing extension of the response, following the
+	// Apollo tracing format. Only requests with the X-Trace-Graphql header
+	// are traced.
+	Tracing bool
+	// MaxBodySize rejects request bodies larger than the given number of
+	// bytes with 413. Multipart requests are limited by MaxUploadSize
+	// instead. Zero means no limit besides the server's maximum request body
+	// size.
+	MaxBodySize int
+	// MaxQueryLength rejects queries longer than the given number of bytes.
+	// Zero means no limit.
+	MaxQueryLength int
+	// MaxVariables rejects requests with more variables than the given
+	// number. Zero means no limit.
+	MaxVariables int
+	// MaxJSONDepth rejects JSON bodies, variables and extensions nested
+	// deeper than the given depth before they are decoded. Zero means no
+	// limit.
+	MaxJSONDepth int
+	// MaxAliases rejects queries with more aliases than the given number
+	// before they are parsed. Zero means no limit.
+	MaxAliases int
+	// MaxTokens rejects queries consisting of more tokens than the given
+	// number before they are parsed. Zero means no limit.
+	MaxTokens int
+	// ConnectionInitWaitTimeout limits the time a graphql-transport-ws client
+	// may take to send its connection_init message. Defaults to three seconds.
+	ConnectionInitWaitTimeout time.Duration
+	// KeepAliveInterval is the interval in which keep-alive messages are sent
+	// to graphql-ws and Server-Sent Events clients. Defaults to twelve
+	// seconds.
+	KeepAliveInterval time.Duration
+	RootObjectFn      RootObjectFn
+	ResultCallbackFn  ResultCallbackFn
+	FormatErrorFn     func(err error) gqlerrors.FormattedError
 }
 
 func NewConfig() *Config {
@@ -224,38 +724,112 @@ func New(p *Config) *Handler {
 		panic("undefined GraphQL schema")
 	}
 
-	return &Handler{
-		Schema:           p.Schema,
-		pretty:           p.Pretty,
-		graphiql:         p.GraphiQL,
-		playground:       p.Playground,
-		rootObjectFn:     p.RootObjectFn,
-		resultCallbackFn: p.ResultCallbackFn,
-		formatErrorFn:    p.FormatErrorFn,
+	var cache *documentCache
+	if p.DocumentCacheSize > 0 {
+		cache = newDocumentCache(p.DocumentCacheSize)
 	}
-}
 
-var staticBox = packr.New("graphql-web", "./static")
+	var rules []graphql.ValidationRuleFn
+	if p.MaxDepth > 0 {
+		rules = append(rules, graphql.SpecifiedRules...)
+		rules = append(rules, maxDepthRule(p.MaxDepth))
+	}
 
-func serveStatic(ctx *fasthttp.RequestCtx) {
-	path := string(ctx.Path())
-	path = path[strings.Index(path, "/static/")+8:]
+	var incrementalSchema *graphql.Schema
+	var schemas *incrementalSchemas
+	if p.IncrementalDelivery {
+		schema, err := incrementalSchemaFor(p.Schema)
+		if err != nil {
+			panic(err)
+		}
+		incrementalSchema = schema
+		if schemas, err = newIncrementalSchemas(schema, p.Tracing); err != nil {
+			panic(err)
+		}
+	}
 
-	content, err := staticBox.Find(path)
-	if errors.Is(err, os.ErrNotExist) {
-		log.Printf("%s - 404 bot found", path)
-		ctx.Response.Header.SetStatusCode(fasthttp.StatusNotFound)
-		return
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = Endpoint
 	}
-	if err != nil {
-		log.Printf("err %v", err)
+	subscriptionEndpoint := p.SubscriptionEndpoint
+	if subscriptionEndpoint == "" {
+		subscriptionEndpoint = SubscriptionEndpoint
+	}
+	basePath := p.BasePath
+	if basePath == "" {
+		basePath = BasePath
+	}
+	if !strings.HasPrefix(basePath, "/") && !strings.Contains(basePath, "://") {
+		basePath = "/" + basePath
+	}
+	var staticAssets map[string]*staticAsset
+	if p.StaticFS != nil {
+		assets, err := loadStaticAssets(p.StaticFS)
+		if err != nil {
+			panic(err)
+		}
+		staticAssets = assets
+	} else if p.GraphiQL || p.Playground {
+		staticAssets = embeddedStaticAssets()
 	}
 
-	contentType := mime.TypeByExtension(filepath.Ext(path))
-	if contentType == "" {
-		contentType = http.DetectContentType(content[:1024])
+	var tracingSchema *graphql.Schema
+	if p.Tracing {
+		if incrementalSchema != nil {
+			tracingSchema = tracingSchemaFor(incrementalSchema)
+		} else {
+			tracingSchema = tracingSchemaFor(p.Schema)
+		}
 	}
-	ctx.Response.Header.SetContentType(contentType)
 
-	ctx.Response.SetBody(content)
+	return &Handler{
+		Schema:                     p.Schema,
+		pretty:                     p.Pretty,
+		graphiql:                   p.GraphiQL,
+		graphiqlSkipResult:         p.GraphiQLSkipResult,
+		playground:                 p.Playground,
+		endpoint:                   endpoint,
+		subscriptionEndpoint:       subscriptionEndpoint,
+		basePath:                   basePath,
+		staticPath:                 staticPathOf(basePath),
+		staticAssets:               staticAssets,
+		staticVersion:              staticVersionOf(staticAssets),
+		subscriptions:              p.Subscriptions,
+		checkOrigin:                p.CheckOrigin,
+		batching:                   p.Batching,
+		maxBatchSize:               p.MaxBatchSize,
+		batchConcurrency:           p.BatchConcurrency,
+		persistedQueryStore:        p.PersistedQueryStore,
+		persistedDocuments:         p.PersistedDocuments,
+		documentCache:              cache,
+		validationRules:            rules,
+		maxComplexity:              p.MaxComplexity,
+		complexity:                 p.Complexity,
+		legacyStatusCodes:          p.LegacyStatusCodes,
+		forbidSubscriptionsOverGET: p.ForbidSubscriptionsOverGET,
+		csrfPrevention:             p.CSRFPrevention,
+		csrfPreventionHeaders:      p.CSRFPreventionHeaders,
+		cors:                       p.CORS,
+		uploads:                    p.Uploads,
+		maxUploadSize:              p.MaxUploadSize,
+		maxUploadFiles:             p.MaxUploadFiles,
+		incrementalDelivery:        p.IncrementalDelivery,
+		incrementalSchema:          incrementalSchema,
+		incrementalSchemas:         schemas,
+		tracingSchema:              tracingSchema,
+		limits: requestLimits{
+			maxBodySize:    p.MaxBodySize,
+			maxQueryLength: p.MaxQueryLength,
+			maxVariables:   p.MaxVariables,
+			maxJSONDepth:   p.MaxJSONDepth,
+			maxAliases:     p.MaxAliases,
+			maxTokens:      p.MaxTokens,
+		},
+		connectionInitWaitTimeout: p.ConnectionInitWaitTimeout,
+		keepAliveInterval:         p.KeepAliveInterval,
+		rootObjectFn:              p.RootObjectFn,
+		resultCallbackFn:          p.ResultCallbackFn,
+		formatErrorFn:             p.FormatErrorFn,
+	}
 }
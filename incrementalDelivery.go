@@ -0,0 +1,792 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/kinds"
+	"github.com/valyala/fasthttp"
+)
+
+// deferSpec is the version of the incremental delivery format, as
+// advertised in the deferSpec parameter of multipart/mixed.
+const deferSpec = "20220824"
+
+// placeholderKey is the response key of the __typename field that is
+// selected in place of selection sets that only consist of deferred
+// fragments. It's removed from the results before they are sent.
+const placeholderKey = "__incrementalPlaceholder"
+
+var deferDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        "defer",
+	Description: "Delivers the fragment after the rest of the response.",
+	Locations: []string{
+		graphql.DirectiveLocationFragmentSpread,
+		graphql.DirectiveLocationInlineFragment,
+	},
+	Args: graphql.FieldConfigArgument{
+		"if": &graphql.ArgumentConfig{
+			Type:         graphql.Boolean,
+			DefaultValue: true,
+		},
+		"label": &graphql.ArgumentConfig{
+			Type: graphql.String,
+		},
+	},
+})
+
+var streamDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        "stream",
+	Description: "Delivers the items of the list after the first initialCount ones after the rest of the response.",
+	Locations: []string{
+		graphql.DirectiveLocationField,
+	},
+	Args: graphql.FieldConfigArgument{
+		"if": &graphql.ArgumentConfig{
+			Type:         graphql.Boolean,
+			DefaultValue: true,
+		},
+		"label": &graphql.ArgumentConfig{
+			Type: graphql.String,
+		},
+		"initialCount": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: 0,
+		},
+	},
+})
+
+// incrementalSchemaFor returns a copy of schema that additionally defines
+// the @defer and @stream directives, unless schema defines them itself.
+func incrementalSchemaFor(schema *graphql.Schema) (*graphql.Schema, error) {
+	directives := schema.Directives()
+	for _, directive := range []*graphql.Directive{deferDirective, streamDirective} {
+		if schema.Directive(directive.Name) == nil {
+			directives = append(directives, directive)
+		}
+	}
+
+	types := []graphql.Type{}
+	for name, t := range schema.TypeMap() {
+		if !strings.HasPrefix(name, "__") {
+			types = append(types, t)
+		}
+	}
+
+	derived, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:        schema.QueryType(),
+		Mutation:     schema.MutationType(),
+		Subscription: schema.SubscriptionType(),
+		Types:        types,
+		Directives:   directives,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &derived, nil
+}
+
+// schema returns the schema operations are validated and executed against.
+func (h *Handler) schema() *graphql.Schema {
+	if h.incrementalSchema != nil {
+		return h.incrementalSchema
+	}
+	return h.Schema
+}
+
+// acceptsMultipartMixed returns true if r accepts incremental results as
+// multipart/mixed in the supported format.
+func acceptsMultipartMixed(r *fasthttp.Request) bool {
+	for _, mediaRange := range strings.Split(string(r.Header.Peek("Accept")), ",") {
+		parts := strings.Split(mediaRange, ";")
+		if strings.TrimSpace(parts[0]) != ContentTypeMultipartMixed {
+			continue
+		}
+		supported := true
+		for _, parameter := range parts[1:] {
+			pair := strings.SplitN(strings.TrimSpace(parameter), "=", 2)
+			if len(pair) == 2 && pair[0] == "deferSpec" && strings.Trim(pair[1], `"`) != deferSpec {
+				supported = false
+			}
+		}
+		if supported {
+			return true
+		}
+	}
+	return false
+}
+
+// initialPayload is the first part of an incremental response.
+type initialPayload struct {
+	Data       interface{}                `json:"data"`
+	Errors     []gqlerrors.FormattedError `json:"errors,omitempty"`
+	Extensions map[string]interface{}     `json:"extensions,omitempty"`
+	HasNext    bool                       `json:"hasNext"`
+}
+
+// subsequentPayload is every further part of an incremental response.
+type subsequentPayload struct {
+	Incremental []incrementalEntry     `json:"incremental,omitempty"`
+	Extensions  map[string]interface{} `json:"extensions,omitempty"`
+	HasNext     bool                   `json:"hasNext"`
+}
+
+// incrementalEntry delivers either the data of a deferred fragment or
+// items of a streamed list at path.
+type incrementalEntry struct {
+	Data   interface{}                `json:"data,omitempty"`
+	Items  []interface{}              `json:"items,omitempty"`
+	Path   []interface{}              `json:"path"`
+	Label  string                     `json:"label,omitempty"`
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+}
+
+// incrementalPart is a subsequent payload on its way to the response
+// writer. Children is the number of parts that follow because of it.
+type incrementalPart struct {
+	entries  []incrementalEntry
+	result   *graphql.Result
+	children int
+}
+
+// serveIncremental executes the query of opts and delivers its deferred
+// fragments and streamed lists as multipart/mixed response in the format
+// of deferSpec 20220824, see https://github.com/graphql/defer-stream-wg
+//
+// The part of the query that is neither deferred nor streamed is executed
+// right away and sent as initial payload. The values its composite fields
+// resolve to are kept, so every deferred fragment is executed afterwards
+// on the objects it applies to, without resolving the fields leading to
+// them again. If one of these values is unknown, e.g. because it has been
+// returned by a thunk, the fragment is executed from the root along these
+// fields instead. Streamed lists are resolved completely, the items beyond
+// initialCount are sent after the initial payload.
+//
+// It returns false without writing a response if opts is no valid query
+// that contains active @defer or @stream directives. Such requests are
+// served as usual.
+func (h *Handler) serveIncremental(reqCtx *fasthttp.RequestCtx, opts *RequestOptions) bool {
+	traced := h.traced(&reqCtx.Request)
+	ctx := context.Context(reqCtx)
+	if traced {
+		ctx = withTracer(reqCtx)
+	}
+
+	if h.limits.checkQuery(opts) != nil || h.resolveQuery(ctx, opts) != nil {
+		return false
+	}
+	doc, errs := h.document(ctx, opts.Query)
+	if errs != nil {
+		return false
+	}
+	operation := selectOperation(doc, opts.OperationName)
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return false
+	}
+	extensions, err := h.checkComplexity(doc, opts.OperationName, opts.Variables)
+	if err != nil {
+		return false
+	}
+
+	planner := newIncrementalPlanner(doc, opts.Variables)
+	selectionSet, deferred, streams := planner.split(operation.SelectionSet, nil)
+	if len(deferred) == 0 && len(streams) == 0 {
+		return false
+	}
+	if len(selectionSet.Selections) == 0 {
+		selectionSet.Selections = []ast.Selection{placeholderField()}
+	}
+
+	execution := &incrementalExecution{
+		handler:   h,
+		operation: operation,
+		opts:      opts,
+		planner:   planner,
+		traced:    traced,
+		captures:  newCaptures(),
+	}
+	if h.rootObjectFn != nil {
+		execution.root = h.rootObjectFn(reqCtx)
+	}
+	params := execution.params(reqCtx)
+
+	result := execution.executeFromRoot(ctx, selectionSet)
+	for key, value := range extensions {
+		if result.Extensions == nil {
+			result.Extensions = map[string]interface{}{}
+		}
+		result.Extensions[key] = value
+	}
+	if result.Data == nil {
+		buff := h.writeResult(reqCtx, resultStatusCode(result), result)
+		if h.resultCallbackFn != nil {
+			h.resultCallbackFn(reqCtx, &params, result, buff)
+		}
+		return true
+	}
+
+	// the deferred fragments are executed on the data before the streamed
+	// lists are truncated, so they also apply to the streamed items
+	execution.data = copyResponse(result.Data)
+	deferred = execution.applicable(deferred)
+	items := streamItems(result.Data, streams)
+	pending := len(deferred)
+	if len(items) > 0 {
+		pending++
+	}
+
+	initial, _ := json.Marshal(initialPayload{
+		Data:       result.Data,
+		Errors:     result.Errors,
+		Extensions: result.Extensions,
+		HasNext:    pending > 0,
+	})
+	if h.resultCallbackFn != nil {
+		h.resultCallbackFn(reqCtx, &params, result, initial)
+	}
+
+	detached := detachedContext(reqCtx)
+
+	reqCtx.SetStatusCode(fasthttp.StatusOK)
+	reqCtx.Response.Header.SetContentType(ContentTypeMultipartMixed + `; boundary="-"; deferSpec=` + deferSpec)
+	reqCtx.Response.Header.Set("Cache-Control", "no-cache")
+
+	reqCtx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		writeMultipartPart(w, initial)
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(detached)
+		defer cancel()
+
+		parts := make(chan incrementalPart)
+		if len(items) > 0 {
+			go execution.send(ctx, parts, incrementalPart{entries: items})
+		}
+		for _, fragment := range deferred {
+			go execution.deliver(ctx, parts, fragment)
+		}
+
+		for pending > 0 {
+			part := <-parts
+			pending += part.children - 1
+			if len(part.entries) == 0 && pending > 0 {
+				// fragments may still turn out to be empty, e.g. below
+				// thunks, but only the last part is needed to end the
+				// response
+				continue
+			}
+
+			subsequent := subsequentPayload{
+				Incremental: part.entries,
+				HasNext:     pending > 0,
+			}
+			if part.result != nil {
+				subsequent.Extensions = part.result.Extensions
+			}
+			payload, _ := json.Marshal(subsequent)
+			if part.result != nil && h.resultCallbackFn != nil {
+				h.resultCallbackFn(ctx, &params, part.result, payload)
+			}
+			writeMultipartPart(w, payload)
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+
+		w.WriteString("\r\n-----\r\n")
+		w.Flush()
+	})
+	return true
+}
+
+// writeMultipartPart writes payload as part of a multipart/mixed response
+// with the boundary "-".
+func writeMultipartPart(w *bufio.Writer, payload []byte) {
+	w.WriteString("\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n")
+	w.Write(payload)
+}
+
+// incrementalExecution executes the parts of an operation that is delivered
+// incrementally.
+type incrementalExecution struct {
+	handler   *Handler
+	operation *ast.OperationDefinition
+	opts      *RequestOptions
+	planner   *incrementalPlanner
+	root      map[string]interface{}
+	traced    bool
+	captures  *captures
+
+	// mu guards data, the response data along with the data of the
+	// deferred fragments delivered so far.
+	mu   sync.Mutex
+	data interface{}
+}
+
+// params returns the parameters that are passed to ResultCallbackFn.
+func (e *incrementalExecution) params(ctx context.Context) graphql.Params {
+	return graphql.Params{
+		Schema:         *e.handler.schema(),
+		RequestString:  e.opts.Query,
+		RootObject:     e.root,
+		VariableValues: e.opts.Variables,
+		OperationName:  e.opts.OperationName,
+		Context:        ctx,
+	}
+}
+
+// executeFromRoot executes the operation with its selection set replaced by
+// selectionSet. The tracer of ctx, if any, times the execution.
+func (e *incrementalExecution) executeFromRoot(ctx context.Context, selectionSet *ast.SelectionSet) *graphql.Result {
+	schema := e.handler.incrementalSchemas.initial
+	if tracerFrom(ctx) != nil {
+		schema = e.handler.incrementalSchemas.initialTraced
+	}
+	return e.execute(withCaptures(ctx, e.captures, nil), schema, e.root, selectionSet)
+}
+
+// execute executes the operation against schema with its selection set
+// replaced by selectionSet.
+func (e *incrementalExecution) execute(ctx context.Context, schema *graphql.Schema, root interface{}, selectionSet *ast.SelectionSet) *graphql.Result {
+	operation := *e.operation
+	operation.SelectionSet = selectionSet
+	ctx, extensions := withExtensions(ctx, e.opts.Extensions)
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema: *schema,
+		Root:   root,
+		AST: &ast.Document{
+			Kind:        kinds.Document,
+			Definitions: []ast.Node{&operation},
+		},
+		OperationName: e.opts.OperationName,
+		Args:          e.opts.Variables,
+		Context:       ctx,
+	})
+	removePlaceholders(result.Data)
+	if tracing, ok := result.Extensions["tracing"].(*tracingResult); ok {
+		// the placeholders aren't part of the operation, so neither are
+		// their resolvers
+		resolvers := []*resolverTrace{}
+		for _, trace := range tracing.Execution.Resolvers {
+			if trace.Path[len(trace.Path)-1] != placeholderKey {
+				resolvers = append(resolvers, trace)
+			}
+		}
+		tracing.Execution.Resolvers = resolvers
+	}
+	extensions.apply(result)
+	e.handler.formatErrors(result)
+	return result
+}
+
+// deliver executes fragment and sends its data along with the items of the
+// lists streamed within it. The fragments deferred within it are delivered
+// once the part has been sent.
+func (e *incrementalExecution) deliver(ctx context.Context, parts chan<- incrementalPart, fragment *deferredFragment) {
+	selectionSet, deferred, streams := e.planner.split(fragment.fragment.SelectionSet, appendSelection(fragment.ancestors, fragment.fragment))
+	if len(selectionSet.Selections) == 0 {
+		selectionSet.Selections = []ast.Selection{placeholderField()}
+	}
+	pruned := *fragment.fragment
+	pruned.SelectionSet = selectionSet
+
+	var result *graphql.Result
+	var entries []incrementalEntry
+	if targets, ok := e.targets(fragment); ok {
+		result, entries = e.executeDeferred(ctx, targets, &pruned)
+	} else {
+		if e.traced {
+			ctx = withTracer(ctx)
+		}
+		result = e.executeFromRoot(ctx, wrapSelection(fragment.ancestors, &pruned))
+		entries = []incrementalEntry{}
+		walkResponse(result.Data, fragment.ancestors, []interface{}{}, func(value interface{}, path []interface{}) {
+			if object, ok := value.(map[string]interface{}); ok && len(object) > 0 {
+				entries = append(entries, incrementalEntry{Data: object, Path: path})
+			}
+		})
+	}
+	e.merge(result.Data)
+	deferred = e.applicable(deferred)
+	items := streamItems(result.Data, streams)
+	for index := range entries {
+		entries[index].Label = fragment.label
+	}
+
+	if len(result.Errors) > 0 {
+		if len(entries) == 0 {
+			entries = append(entries, incrementalEntry{Path: responsePath(fragment.ancestors), Label: fragment.label})
+		}
+		entries[0].Errors = result.Errors
+	}
+	entries = append(entries, items...)
+
+	if !e.send(ctx, parts, incrementalPart{entries: entries, result: result, children: len(deferred)}) {
+		return
+	}
+	for _, nested := range deferred {
+		go e.deliver(ctx, parts, nested)
+	}
+}
+
+// send passes part to the response writer and returns false if ctx is done
+// before.
+func (e *incrementalExecution) send(ctx context.Context, parts chan<- incrementalPart, part incrementalPart) bool {
+	select {
+	case parts <- part:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// deferredFragment is a fragment marked with @defer. The ancestors are the
+// fields and fragments from the root of the operation down to it.
+type deferredFragment struct {
+	label     string
+	ancestors []ast.Selection
+	fragment  *ast.InlineFragment
+}
+
+// streamedField is a field marked with @stream. The ancestors are the
+// fields and fragments from the root of the operation down to the field
+// itself.
+type streamedField struct {
+	label        string
+	initialCount int
+	ancestors    []ast.Selection
+}
+
+// incrementalPlanner splits selection sets into the part that is executed
+// right away and the deferred fragments and streamed fields.
+type incrementalPlanner struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func newIncrementalPlanner(doc *ast.Document, variables map[string]interface{}) *incrementalPlanner {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	return &incrementalPlanner{fragments: fragments, variables: variables}
+}
+
+// split returns a copy of selectionSet without the deferred fragments it
+// contains, which are returned separately along with the streamed fields.
+// Fragment spreads are replaced by inline fragments, so the returned
+// selection sets don't depend on fragment definitions.
+func (p *incrementalPlanner) split(selectionSet *ast.SelectionSet, ancestors []ast.Selection) (*ast.SelectionSet, []*deferredFragment, []*streamedField) {
+	pruned := &ast.SelectionSet{Kind: kinds.SelectionSet, Loc: selectionSet.Loc}
+	deferred := []*deferredFragment{}
+	streams := []*streamedField{}
+
+	for _, selection := range selectionSet.Selections {
+		var fragment *ast.InlineFragment
+		switch selection := selection.(type) {
+		case *ast.Field:
+			field := *selection
+			if directive, ok := p.activeDirective(field.Directives, streamDirective.Name); ok {
+				initialCount, _ := p.argument(directive, "initialCount").(int)
+				if initialCount < 0 {
+					initialCount = 0
+				}
+				label, _ := p.argument(directive, "label").(string)
+				streams = append(streams, &streamedField{
+					label:        label,
+					initialCount: initialCount,
+					ancestors:    appendSelection(ancestors, &field),
+				})
+			}
+			if field.SelectionSet != nil {
+				childSelectionSet, childDeferred, childStreams := p.split(field.SelectionSet, appendSelection(ancestors, &field))
+				// the placeholder also reveals the type of the objects
+				// fragments are deferred on
+				if len(childSelectionSet.Selections) == 0 || defersOn(childDeferred, &field) {
+					childSelectionSet.Selections = append(childSelectionSet.Selections, placeholderField())
+				}
+				field.SelectionSet = childSelectionSet
+				deferred = append(deferred, childDeferred...)
+				streams = append(streams, childStreams...)
+			}
+			pruned.Selections = append(pruned.Selections, &field)
+			continue
+		case *ast.InlineFragment:
+			copied := *selection
+			fragment = &copied
+		case *ast.FragmentSpread:
+			definition, ok := p.fragments[selection.Name.Value]
+			if !ok {
+				continue
+			}
+			fragment = &ast.InlineFragment{
+				Kind:          kinds.InlineFragment,
+				Loc:           selection.Loc,
+				TypeCondition: definition.TypeCondition,
+				Directives:    selection.Directives,
+				SelectionSet:  definition.SelectionSet,
+			}
+		default:
+			pruned.Selections = append(pruned.Selections, selection)
+			continue
+		}
+
+		if directive, ok := p.activeDirective(fragment.Directives, deferDirective.Name); ok {
+			fragment.Directives = removeDirective(fragment.Directives, directive)
+			label, _ := p.argument(directive, "label").(string)
+			deferred = append(deferred, &deferredFragment{
+				label:     label,
+				ancestors: appendSelection(ancestors),
+				fragment:  fragment,
+			})
+			continue
+		}
+
+		childSelectionSet, childDeferred, childStreams := p.split(fragment.SelectionSet, appendSelection(ancestors, fragment))
+		fragment.SelectionSet = childSelectionSet
+		deferred = append(deferred, childDeferred...)
+		streams = append(streams, childStreams...)
+		if len(childSelectionSet.Selections) > 0 {
+			pruned.Selections = append(pruned.Selections, fragment)
+		}
+	}
+
+	return pruned, deferred, streams
+}
+
+// activeDirective returns the directive with the given name unless its if
+// argument is false.
+func (p *incrementalPlanner) activeDirective(directives []*ast.Directive, name string) (*ast.Directive, bool) {
+	for _, directive := range directives {
+		if directive.Name == nil || directive.Name.Value != name {
+			continue
+		}
+		if enabled, ok := p.argument(directive, "if").(bool); ok && !enabled {
+			return nil, false
+		}
+		return directive, true
+	}
+	return nil, false
+}
+
+// included returns false if the @skip or @include directives of a selection
+// exclude it.
+func (p *incrementalPlanner) included(directives []*ast.Directive) bool {
+	for _, directive := range directives {
+		if directive.Name == nil {
+			continue
+		}
+		switch directive.Name.Value {
+		case graphql.SkipDirective.Name:
+			if skip, _ := p.argument(directive, "if").(bool); skip {
+				return false
+			}
+		case graphql.IncludeDirective.Name:
+			if include, ok := p.argument(directive, "if").(bool); ok && !include {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// argument returns the value of the named argument of directive, or nil if
+// it's not given.
+func (p *incrementalPlanner) argument(directive *ast.Directive, name string) interface{} {
+	for _, argument := range directive.Arguments {
+		if argument.Name == nil || argument.Name.Value != name {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.Variable:
+			if number, ok := p.variables[value.Name.Value].(float64); ok {
+				return int(number)
+			}
+			return p.variables[value.Name.Value]
+		case *ast.BooleanValue:
+			return value.Value
+		case *ast.IntValue:
+			number, _ := strconv.Atoi(value.Value)
+			return number
+		case *ast.StringValue:
+			return value.Value
+		}
+	}
+	return nil
+}
+
+// wrapSelection returns a selection set that selects selection through the
+// given ancestors.
+func wrapSelection(ancestors []ast.Selection, selection ast.Selection) *ast.SelectionSet {
+	selectionSet := &ast.SelectionSet{Kind: kinds.SelectionSet, Selections: []ast.Selection{selection}}
+	for index := len(ancestors) - 1; index >= 0; index-- {
+		switch ancestor := ancestors[index].(type) {
+		case *ast.Field:
+			field := *ancestor
+			field.SelectionSet = selectionSet
+			selection = &field
+		case *ast.InlineFragment:
+			fragment := *ancestor
+			fragment.SelectionSet = selectionSet
+			selection = &fragment
+		}
+		selectionSet = &ast.SelectionSet{Kind: kinds.SelectionSet, Selections: []ast.Selection{selection}}
+	}
+	return selectionSet
+}
+
+// streamItems truncates the lists of the streamed fields in data to their
+// initial count and returns the remaining items.
+func streamItems(data interface{}, streams []*streamedField) []incrementalEntry {
+	// inner lists are truncated first, so the items of outer lists are
+	// delivered in their final shape, but before the inner items
+	groups := make([][]incrementalEntry, len(streams))
+	for index := len(streams) - 1; index >= 0; index-- {
+		stream := streams[index]
+		field := stream.ancestors[len(stream.ancestors)-1].(*ast.Field)
+		key := responseKey(field)
+		walkResponse(data, stream.ancestors[:len(stream.ancestors)-1], []interface{}{}, func(value interface{}, path []interface{}) {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return
+			}
+			list, ok := object[key].([]interface{})
+			if !ok || len(list) <= stream.initialCount {
+				return
+			}
+			object[key] = list[:stream.initialCount]
+			for itemIndex := stream.initialCount; itemIndex < len(list); itemIndex++ {
+				groups[index] = append(groups[index], incrementalEntry{
+					Items: []interface{}{list[itemIndex]},
+					Path:  appendPath(path, key, itemIndex),
+					Label: stream.label,
+				})
+			}
+		})
+	}
+
+	entries := []incrementalEntry{}
+	for _, group := range groups {
+		entries = append(entries, group...)
+	}
+	return entries
+}
+
+// walkResponse calls visit with every value of data that is reached by the
+// fields of ancestors, along with its path. Lists are descended into.
+func walkResponse(data interface{}, ancestors []ast.Selection, path []interface{}, visit func(value interface{}, path []interface{})) {
+	if list, ok := data.([]interface{}); ok {
+		for index, item := range list {
+			walkResponse(item, ancestors, appendPath(path, index), visit)
+		}
+		return
+	}
+	if len(ancestors) == 0 {
+		visit(data, path)
+		return
+	}
+	field, ok := ancestors[0].(*ast.Field)
+	if !ok {
+		walkResponse(data, ancestors[1:], path, visit)
+		return
+	}
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	key := responseKey(field)
+	if value, ok := object[key]; ok {
+		walkResponse(value, ancestors[1:], appendPath(path, key), visit)
+	}
+}
+
+// responsePath returns the response keys of the fields of ancestors.
+func responsePath(ancestors []ast.Selection) []interface{} {
+	path := []interface{}{}
+	for _, ancestor := range ancestors {
+		if field, ok := ancestor.(*ast.Field); ok {
+			path = append(path, responseKey(field))
+		}
+	}
+	return path
+}
+
+// removePlaceholders removes the placeholder fields from data.
+func removePlaceholders(data interface{}) {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		delete(value, placeholderKey)
+		for _, child := range value {
+			removePlaceholders(child)
+		}
+	case []interface{}:
+		for _, child := range value {
+			removePlaceholders(child)
+		}
+	}
+}
+
+func placeholderField() *ast.Field {
+	return &ast.Field{
+		Kind:  kinds.Field,
+		Alias: &ast.Name{Kind: kinds.Name, Value: placeholderKey},
+		Name:  &ast.Name{Kind: kinds.Name, Value: "__typename"},
+	}
+}
+
+// defersOn returns true if any of the deferred fragments is deferred on the
+// objects of field.
+func defersOn(deferred []*deferredFragment, field *ast.Field) bool {
+	for _, fragment := range deferred {
+		for index := len(fragment.ancestors) - 1; index >= 0; index-- {
+			if parent, ok := fragment.ancestors[index].(*ast.Field); ok {
+				if parent == field {
+					return true
+				}
+				break
+			}
+		}
+	}
+	return false
+}
+
+func responseKey(field *ast.Field) string {
+	if field.Alias != nil && field.Alias.Value != "" {
+		return field.Alias.Value
+	}
+	return field.Name.Value
+}
+
+func removeDirective(directives []*ast.Directive, removed *ast.Directive) []*ast.Directive {
+	result := []*ast.Directive{}
+	for _, directive := range directives {
+		if directive != removed {
+			result = append(result, directive)
+		}
+	}
+	return result
+}
+
+func appendSelection(selections []ast.Selection, additional ...ast.Selection) []ast.Selection {
+	result := make([]ast.Selection, 0, len(selections)+len(additional))
+	result = append(result, selections...)
+	return append(result, additional...)
+}
+
+func appendPath(path []interface{}, additional ...interface{}) []interface{} {
+	result := make([]interface{}, 0, len(path)+len(additional))
+	result = append(result, path...)
+	return append(result, additional...)
+}
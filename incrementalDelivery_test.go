@@ -0,0 +1,364 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestIncrementalDelivery(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:              &testutil.StarWarsSchema,
+		IncrementalDelivery: true,
+	})
+
+	cases := map[string]struct {
+		query            string
+		expectedPayloads []string
+	}{
+		"Defer": {
+			query: `{ hero { id ... @defer(label: "friends") { friends { name } } } }`,
+			expectedPayloads: []string{
+				`{"data": {"hero": {"id": "2001"}}, "hasNext": true}`,
+				`{"incremental": [{"data": {"friends": [{"name": "Luke Skywalker"}, {"name": "Han Solo"}, {"name": "Leia Organa"}]}, "path": ["hero"], "label": "friends"}], "hasNext": false}`,
+			},
+		},
+		"DeferFragmentSpread": {
+			query: `{ ...Hero @defer } fragment Hero on Query { hero { name } }`,
+			expectedPayloads: []string{
+				`{"data": {}, "hasNext": true}`,
+				`{"incremental": [{"data": {"hero": {"name": "R2-D2"}}, "path": []}], "hasNext": false}`,
+			},
+		},
+		"DeferWithinList": {
+			query: `{ hero { friends { name ... on Human @defer { id } } } }`,
+			expectedPayloads: []string{
+				`{"data": {"hero": {"friends": [{"name": "Luke Skywalker"}, {"name": "Han Solo"}, {"name": "Leia Organa"}]}}, "hasNext": true}`,
+				`{"incremental": [` +
+					`{"data": {"id": "1000"}, "path": ["hero", "friends", 0]}, ` +
+					`{"data": {"id": "1002"}, "path": ["hero", "friends", 1]}, ` +
+					`{"data": {"id": "1003"}, "path": ["hero", "friends", 2]}` +
+					`], "hasNext": false}`,
+			},
+		},
+		"DeferNotApplying": {
+			query: `{ hero { id ... on Human @defer { homePlanet } } }`,
+			expectedPayloads: []string{
+				`{"data": {"hero": {"id": "2001"}}, "hasNext": false}`,
+			},
+		},
+		"DeferPartlyApplying": {
+			query: `{ hero { id ... on Human @defer { homePlanet } ... on Droid @defer { primaryFunction } } }`,
+			expectedPayloads: []string{
+				`{"data": {"hero": {"id": "2001"}}, "hasNext": true}`,
+				`{"incremental": [{"data": {"primaryFunction": "Astromech"}, "path": ["hero"]}], "hasNext": false}`,
+			},
+		},
+		"DeferEmpty": {
+			query: `{ hero { id ... @defer { name @skip(if: true) } } }`,
+			expectedPayloads: []string{
+				`{"data": {"hero": {"id": "2001"}}, "hasNext": true}`,
+				`{"hasNext": false}`,
+			},
+		},
+		"Stream": {
+			query: `{ hero { friends @stream(initialCount: 1) { name } } }`,
+			expectedPayloads: []string{
+				`{"data": {"hero": {"friends": [{"name": "Luke Skywalker"}]}}, "hasNext": true}`,
+				`{"incremental": [` +
+					`{"items": [{"name": "Han Solo"}], "path": ["hero", "friends", 1]}, ` +
+					`{"items": [{"name": "Leia Organa"}], "path": ["hero", "friends", 2]}` +
+					`], "hasNext": false}`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resp := serveIncremental(t, h, tc.query, "multipart/mixed; deferSpec=20220824, application/json")
+			defer fasthttp.ReleaseResponse(resp)
+
+			if code := resp.StatusCode(); code != http.StatusOK {
+				t.Fatalf("unexpected server response %v: %s", code, resp.Body())
+			}
+			mediaType, params, err := mime.ParseMediaType(string(resp.Header.ContentType()))
+			if err != nil || mediaType != "multipart/mixed" || params["deferspec"] != "20220824" {
+				t.Fatalf("unexpected content type %s", resp.Header.ContentType())
+			}
+
+			payloads := readMultipartPayloads(t, resp.Body(), params["boundary"])
+			expected := make([]interface{}, len(tc.expectedPayloads))
+			for i, payload := range tc.expectedPayloads {
+				if err := json.Unmarshal([]byte(payload), &expected[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(payloads, expected) {
+				t.Fatalf("wrong payloads, diff: %v", testutil.Diff(expected, payloads))
+			}
+		})
+	}
+}
+
+func TestIncrementalDelivery_SingleResponse(t *testing.T) {
+	query := `{ hero { id ... @defer { name } friends @stream { name } } }`
+	expected := map[string]interface{}{
+		"hero": map[string]interface{}{
+			"id":   "2001",
+			"name": "R2-D2",
+			"friends": []interface{}{
+				map[string]interface{}{"name": "Luke Skywalker"},
+				map[string]interface{}{"name": "Han Solo"},
+				map[string]interface{}{"name": "Leia Organa"},
+			},
+		},
+	}
+
+	t.Run("NotAccepted", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:              &testutil.StarWarsSchema,
+			IncrementalDelivery: true,
+		})
+		resp := serveIncremental(t, h, query, "application/json")
+		defer fasthttp.ReleaseResponse(resp)
+
+		result := decodeResponse(t, resp)
+		if resp.StatusCode() != http.StatusOK || !reflect.DeepEqual(result.Data, expected) {
+			t.Fatalf("unexpected response %d %s", resp.StatusCode(), resp.Body())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:              &testutil.StarWarsSchema,
+			IncrementalDelivery: true,
+		})
+		resp := serveIncremental(t, h, `{ hero { id ... @defer(if: false) { name } } }`, "multipart/mixed, application/json")
+		defer fasthttp.ReleaseResponse(resp)
+
+		if contentType := string(resp.Header.ContentType()); contentType != "application/json; charset=utf-8" {
+			t.Fatalf("unexpected content type %s", contentType)
+		}
+		result := decodeResponse(t, resp)
+		if !reflect.DeepEqual(result.Data, map[string]interface{}{"hero": map[string]interface{}{"id": "2001", "name": "R2-D2"}}) {
+			t.Fatalf("unexpected response %s", resp.Body())
+		}
+	})
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema: &testutil.StarWarsSchema,
+		})
+		resp := serveIncremental(t, h, query, "multipart/mixed, application/graphql-response+json")
+		defer fasthttp.ReleaseResponse(resp)
+
+		result := decodeResponse(t, resp)
+		if resp.StatusCode() != http.StatusBadRequest || len(result.Errors) != 2 || result.Errors[0].Message != `Unknown directive "defer".` {
+			t.Fatalf("unexpected response %d %s", resp.StatusCode(), resp.Body())
+		}
+	})
+}
+
+func TestIncrementalDelivery_ResolvesAncestorsOnce(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	count := func(field string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[field]++
+	}
+
+	var userType *graphql.Object
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":   &graphql.Field{Type: graphql.ID},
+				"name": &graphql.Field{Type: graphql.String},
+				"friends": &graphql.Field{
+					Type: graphql.NewList(userType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						count("friends")
+						return p.Source.(map[string]interface{})["friends"], nil
+					},
+				},
+			}
+		}),
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"user": &graphql.Field{
+					Type: userType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						count("user")
+						return map[string]interface{}{
+							"id":   "1",
+							"name": "Alice",
+							"friends": []interface{}{
+								map[string]interface{}{"id": "2", "name": "Bob"},
+								map[string]interface{}{"id": "3", "name": "Carol"},
+							},
+						}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:              &schema,
+		IncrementalDelivery: true,
+	})
+	query := `{ user { id ... @defer(label: "outer") { name friends { id ... on User @defer(label: "inner") { name } } } } }`
+	resp := serveIncremental(t, h, query, "multipart/mixed; deferSpec=20220824")
+	defer fasthttp.ReleaseResponse(resp)
+
+	_, params, err := mime.ParseMediaType(string(resp.Header.ContentType()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := readMultipartPayloads(t, resp.Body(), params["boundary"])
+	expectedPayloads := []string{
+		`{"data": {"user": {"id": "1"}}, "hasNext": true}`,
+		`{"incremental": [{"data": {"name": "Alice", "friends": [{"id": "2"}, {"id": "3"}]}, "path": ["user"], "label": "outer"}], "hasNext": true}`,
+		`{"incremental": [` +
+			`{"data": {"name": "Bob"}, "path": ["user", "friends", 0], "label": "inner"}, ` +
+			`{"data": {"name": "Carol"}, "path": ["user", "friends", 1], "label": "inner"}` +
+			`], "hasNext": false}`,
+	}
+	expected := make([]interface{}, len(expectedPayloads))
+	for i, payload := range expectedPayloads {
+		if err := json.Unmarshal([]byte(payload), &expected[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(payloads, expected) {
+		t.Fatalf("wrong payloads, diff: %v", testutil.Diff(expected, payloads))
+	}
+
+	if expectedCalls := map[string]int{"user": 1, "friends": 1}; !reflect.DeepEqual(calls, expectedCalls) {
+		t.Fatalf("expected resolver calls %v, got %v", expectedCalls, calls)
+	}
+}
+
+func TestIncrementalDelivery_Tracing(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:              &testutil.StarWarsSchema,
+		IncrementalDelivery: true,
+		Tracing:             true,
+	})
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/graphql")
+	req.Header.Set("Accept", "multipart/mixed; deferSpec=20220824")
+	req.Header.Set(handler.TracingHeader, "1")
+	req.URI().SetPath("/graphql")
+	req.SetBodyString(`{ hero { id ... @defer { name } } }`)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	_, params, err := mime.ParseMediaType(string(resp.Header.ContentType()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := readMultipartPayloads(t, resp.Body(), params["boundary"])
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 payloads, got %v", payloads)
+	}
+
+	expectedPaths := [][]interface{}{
+		{[]interface{}{"hero"}, []interface{}{"hero", "id"}},
+		{[]interface{}{"hero", "name"}},
+	}
+	for index, payload := range payloads {
+		var traced struct {
+			Extensions struct {
+				Tracing *struct {
+					Execution struct {
+						Resolvers []struct {
+							Path []interface{} `json:"path"`
+						} `json:"resolvers"`
+					} `json:"execution"`
+				} `json:"tracing"`
+			} `json:"extensions"`
+		}
+		data, _ := json.Marshal(payload)
+		if err := json.Unmarshal(data, &traced); err != nil {
+			t.Fatal(err)
+		}
+		if traced.Extensions.Tracing == nil {
+			t.Fatalf("expected tracing extension in payload %d: %s", index, data)
+		}
+		paths := []interface{}{}
+		for _, resolver := range traced.Extensions.Tracing.Execution.Resolvers {
+			paths = append(paths, resolver.Path)
+		}
+		if !reflect.DeepEqual(paths, expectedPaths[index]) {
+			t.Fatalf("expected resolver paths %v in payload %d, got %v", expectedPaths[index], index, paths)
+		}
+	}
+}
+
+func serveIncremental(t *testing.T, h *handler.Handler, query, accept string) *fasthttp.Response {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/graphql")
+	req.Header.Set("Accept", accept)
+	req.URI().SetPath("/graphql")
+	req.SetBodyString(query)
+
+	resp := fasthttp.AcquireResponse()
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func readMultipartPayloads(t *testing.T, body []byte, boundary string) []interface{} {
+	payloads := []interface{}{}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		if contentType := part.Header.Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+			t.Fatalf("unexpected part content type %s", contentType)
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload interface{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("invalid payload %s: %v", data, err)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/lexer"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/valyala/fasthttp"
+)
+
+// requestLimits bounds the size of requests, so oversized payloads are
+// rejected before they are decoded or parsed. Zero values mean no limit.
+type requestLimits struct {
+	maxBodySize    int
+	maxQueryLength int
+	maxVariables   int
+	maxJSONDepth   int
+	maxAliases     int
+	maxTokens      int
+}
+
+// checkBody checks the body of r and the JSON encoded request parameters
+// before they are decoded. Bodies of multipart requests are limited by the
+// upload limits instead.
+func (l *requestLimits) checkBody(r *fasthttp.Request) error {
+	if l.maxBodySize > 0 && !isMultipartRequest(r) && len(r.Body()) > l.maxBodySize {
+		return &statusError{
+			status:  fasthttp.StatusRequestEntityTooLarge,
+			message: fmt.Sprintf("Request body exceeds the maximum size of %d bytes.", l.maxBodySize),
+		}
+	}
+	if l.maxJSONDepth <= 0 {
+		return nil
+	}
+
+	if err := l.checkArgs(r.URI().QueryArgs()); err != nil {
+		return err
+	}
+	if !r.Header.IsPost() {
+		return nil
+	}
+	switch mediaTypeOf(r) {
+	case ContentTypeGraphQL, ContentTypeMultipartFormData:
+		return nil
+	case ContentTypeFormURLEncoded:
+		return l.checkArgs(r.PostArgs())
+	default:
+		return l.checkJSONDepth(r.Body())
+	}
+}
+
+// checkArgs checks the JSON encoded variables and extensions of args.
+func (l *requestLimits) checkArgs(args *fasthttp.Args) error {
+	for _, key := range []string{"variables", "extensions"} {
+		if err := l.checkJSONDepth(args.Peek(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkJSONDepth checks the nesting depth of the JSON document data without
+// decoding it.
+func (l *requestLimits) checkJSONDepth(data []byte) error {
+	if l.maxJSONDepth <= 0 {
+		return nil
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+	for _, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+			if depth > l.maxJSONDepth {
+				return &statusError{
+					status:  fasthttp.StatusBadRequest,
+					message: fmt.Sprintf("Request JSON exceeds the maximum nesting depth of %d.", l.maxJSONDepth),
+				}
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// checkQuery checks the query and variables of opts before the query gets
+// parsed. Tokens and aliases are counted by lexing the query, syntax errors
+// are left to the parser.
+func (l *requestLimits) checkQuery(opts *RequestOptions) error {
+	if l.maxQueryLength > 0 && len(opts.Query) > l.maxQueryLength {
+		return &statusError{
+			status:  fasthttp.StatusBadRequest,
+			message: fmt.Sprintf("Query exceeds the maximum length of %d bytes.", l.maxQueryLength),
+		}
+	}
+	if l.maxVariables > 0 && len(opts.Variables) > l.maxVariables {
+		return &statusError{
+			status:  fasthttp.StatusBadRequest,
+			message: fmt.Sprintf("Request has %d variables, which exceeds the maximum of %d.", len(opts.Variables), l.maxVariables),
+		}
+	}
+	if (l.maxTokens <= 0 && l.maxAliases <= 0) || strings.TrimSpace(opts.Query) == "" {
+		return nil
+	}
+
+	next := lexer.Lex(source.NewSource(&source.Source{Body: []byte(opts.Query)}))
+	tokens, aliases, parens := 0, 0, 0
+	previous := lexer.Token{}
+	for {
+		token, err := next(0)
+		if err != nil || token.Kind == lexer.EOF {
+			break
+		}
+		tokens++
+		if l.maxTokens > 0 && tokens > l.maxTokens {
+			return &statusError{
+				status:  fasthttp.StatusBadRequest,
+				message: fmt.Sprintf("Query exceeds the maximum of %d tokens.", l.maxTokens),
+			}
+		}
+
+		switch token.Kind {
+		case lexer.PAREN_L:
+			parens++
+		case lexer.PAREN_R:
+			parens--
+		case lexer.COLON:
+			// outside of arguments and variable definitions, a name followed
+			// by a colon is an alias
+			if parens == 0 && previous.Kind == lexer.NAME {
+				aliases++
+			}
+		}
+		previous = token
+	}
+
+	if l.maxAliases > 0 && aliases > l.maxAliases {
+		return &statusError{
+			status:  fasthttp.StatusBadRequest,
+			message: fmt.Sprintf("Query has %d aliases, which exceeds the maximum of %d.", aliases, l.maxAliases),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestRequestLimits(t *testing.T) {
+	cases := map[string]struct {
+		config          handler.Config
+		method          string
+		contentType     string
+		uri             string
+		body            string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		"WithinLimits": {
+			config: handler.Config{
+				MaxBodySize:    1000,
+				MaxQueryLength: 100,
+				MaxVariables:   1,
+				MaxJSONDepth:   3,
+				MaxAliases:     1,
+				MaxTokens:      30,
+			},
+			body:           `{"query": "query H($id: String!) { h: human(id: $id) { name } }", "variables": {"id": "1000"}}`,
+			expectedStatus: http.StatusOK,
+		},
+		"MaxBodySize": {
+			config:          handler.Config{MaxBodySize: 10},
+			body:            `{"query": "{ hero { name } }"}`,
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedMessage: "Request body exceeds the maximum size of 10 bytes.",
+		},
+		"MaxQueryLength": {
+			config:          handler.Config{MaxQueryLength: 10},
+			body:            `{"query": "{ hero { name } }"}`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Query exceeds the maximum length of 10 bytes.",
+		},
+		"MaxVariables": {
+			config:          handler.Config{MaxVariables: 1},
+			body:            `{"query": "{ hero { name } }", "variables": {"a": 1, "b": 2}}`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Request has 2 variables, which exceeds the maximum of 1.",
+		},
+		"MaxJSONDepth": {
+			config:          handler.Config{MaxJSONDepth: 3},
+			body:            `{"query": "{ hero { name } }", "variables": {"a": [[1]]}}`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Request JSON exceeds the maximum nesting depth of 3.",
+		},
+		"MaxJSONDepthIgnoresStrings": {
+			config:         handler.Config{MaxJSONDepth: 2},
+			body:           `{"query": "{ hero { name } }", "variables": {"a": "[[\"{{"}}`,
+			expectedStatus: http.StatusOK,
+		},
+		"MaxJSONDepthOfQueryArgs": {
+			config:          handler.Config{MaxJSONDepth: 1},
+			method:          fasthttp.MethodGet,
+			uri:             `/graphql?query={hero{name}}&variables={"a":{"b":1}}`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Request JSON exceeds the maximum nesting depth of 1.",
+		},
+		"MaxAliases": {
+			config:          handler.Config{MaxAliases: 1},
+			contentType:     "application/graphql",
+			body:            `query Q($id: String = "1000") { a: hero { name } b: human(id: $id) { n: name } }`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Query has 3 aliases, which exceeds the maximum of 1.",
+		},
+		"MaxTokens": {
+			config:          handler.Config{MaxTokens: 5},
+			contentType:     "application/graphql",
+			body:            `{ hero { name } }`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Query exceeds the maximum of 5 tokens.",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := tc.config
+			config.Schema = &testutil.StarWarsSchema
+			h := handler.New(&config)
+
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetHost("localhost")
+			req.Header.Set("Accept", "application/graphql-response+json")
+			if tc.method == fasthttp.MethodGet {
+				req.Header.SetMethod(fasthttp.MethodGet)
+				req.SetRequestURI(tc.uri)
+			} else {
+				req.Header.SetMethod(fasthttp.MethodPost)
+				req.URI().SetPath("/graphql")
+				contentType := tc.contentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				req.Header.SetContentType(contentType)
+				req.SetBodyString(tc.body)
+			}
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if code := resp.StatusCode(); code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, code, resp.Body())
+			}
+			result := decodeResponse(t, resp)
+			if tc.expectedMessage == "" {
+				if len(result.Errors) > 0 {
+					t.Fatalf("unexpected errors %v", result.Errors)
+				}
+				return
+			}
+			if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, tc.expectedMessage) {
+				t.Fatalf("expected error %q, got %v", tc.expectedMessage, result.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+var errNotAcceptable = &statusError{
+	status:  fasthttp.StatusNotAcceptable,
+	message: "Not acceptable, accept " + ContentTypeGraphQLResponse + " or " + ContentTypeJSON + ".",
+}
+
+// negotiateMediaType picks the media type of the response from the given
+// Accept header. If the header is empty, application/json is used for
+// compatibility with older clients. If the client accepts neither
+// application/graphql-response+json nor application/json, false is returned.
+func negotiateMediaType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return ContentTypeJSON, true
+	}
+
+	selected, selectedQ, selectedSpecificity := "", 0.0, 0
+	for _, mediaType := range []string{ContentTypeGraphQLResponse, ContentTypeJSON} {
+		q, specificity := acceptQuality(accept, mediaType)
+		if q <= 0 {
+			continue
+		}
+		// on equal quality, the more specifically named media type wins. If
+		// both are named explicitly, application/graphql-response+json is
+		// preferred, if both only match wildcards, application/json is.
+		if q > selectedQ || (q == selectedQ && (specificity > selectedSpecificity || (specificity == selectedSpecificity && specificity < 3))) {
+			selected, selectedQ, selectedSpecificity = mediaType, q, specificity
+		}
+	}
+	return selected, selected != ""
+}
+
+// acceptQuality returns the quality the Accept header assigns to mediaType
+// along with the specificity of the matching media range. The most specific
+// range takes precedence, see RFC 7231 section 5.3.2.
+func acceptQuality(accept, mediaType string) (float64, int) {
+	mainType := mediaType[:strings.Index(mediaType, "/")]
+
+	quality, specificity := 0.0, 0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		parameters := strings.Split(mediaRange, ";")
+		name := strings.ToLower(strings.TrimSpace(parameters[0]))
+
+		var rangeSpecificity int
+		switch name {
+		case mediaType:
+			rangeSpecificity = 3
+		case mainType + "/*":
+			rangeSpecificity = 2
+		case "*/*":
+			rangeSpecificity = 1
+		default:
+			continue
+		}
+		if rangeSpecificity <= specificity {
+			continue
+		}
+
+		q := 1.0
+		for _, parameter := range parameters[1:] {
+			parameter = strings.TrimSpace(parameter)
+			if !strings.HasPrefix(parameter, "q=") {
+				continue
+			}
+			if value, err := strconv.ParseFloat(parameter[2:], 64); err == nil {
+				q = value
+			}
+		}
+		quality, specificity = q, rangeSpecificity
+	}
+	return quality, specificity
+}
+
+// acceptable returns true if the handler is able to respond to r in a media
+// type it accepts. Requests for the raw response of an IDE are answered with
+// application/json anyway.
+func (h *Handler) acceptable(r *fasthttp.Request) bool {
+	if h.legacyStatusCodes || h.rendersIDE(r) || r.URI().QueryArgs().Has("raw") {
+		return true
+	}
+	if h.subscriptions && acceptsEventStream(r) {
+		return true
+	}
+	if h.incrementalDelivery && acceptsMultipartMixed(r) {
+		return true
+	}
+	_, ok := negotiateMediaType(string(r.Header.Peek("Accept")))
+	return ok
+}
@@ -0,0 +1,111 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestMediaTypeNegotiation(t *testing.T) {
+	testCases := map[string]struct {
+		accept              string
+		query               string
+		legacyStatusCodes   bool
+		expectedStatus      int
+		expectedContentType string
+	}{
+		"NoAccept": {
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+		},
+		"GraphQLResponse": {
+			accept:              "application/graphql-response+json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/graphql-response+json; charset=utf-8",
+		},
+		"BothAccepted": {
+			accept:              "application/json, application/graphql-response+json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/graphql-response+json; charset=utf-8",
+		},
+		"QualityPrefersGraphQLResponse": {
+			accept:              "application/json;q=0.5, application/graphql-response+json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/graphql-response+json; charset=utf-8",
+		},
+		"QualityPrefersJSON": {
+			accept:              "application/graphql-response+json;q=0.1, application/json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+		},
+		"Wildcard": {
+			accept:              "text/html, */*;q=0.8",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+		},
+		"SpecificRangeWins": {
+			accept:              "application/*, application/json;q=0",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/graphql-response+json; charset=utf-8",
+		},
+		"NotAcceptable": {
+			accept:              "text/plain",
+			expectedStatus:      http.StatusNotAcceptable,
+			expectedContentType: "application/json; charset=utf-8",
+		},
+		"GraphQLResponseValidationError": {
+			accept:              "application/graphql-response+json",
+			query:               "{ unknown }",
+			legacyStatusCodes:   true,
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "application/graphql-response+json; charset=utf-8",
+		},
+		"LegacyValidationError": {
+			accept:              "application/json",
+			query:               "{ unknown }",
+			legacyStatusCodes:   true,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := handler.New(&handler.Config{
+				Schema:            &statusSchema,
+				LegacyStatusCodes: testCase.legacyStatusCodes,
+			})
+
+			query := testCase.query
+			if query == "" {
+				query = "{ name }"
+			}
+
+			req := fasthttp.AcquireRequest()
+			req.Header.SetHost("localhost")
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.SetContentType("application/graphql")
+			req.Header.Set("Accept", testCase.accept)
+			req.URI().SetPath("/graphql")
+			req.SetBodyString(query)
+			defer fasthttp.ReleaseRequest(req)
+
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if code := resp.StatusCode(); code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatus, code, resp.Body())
+			}
+			if contentType := string(resp.Header.ContentType()); contentType != testCase.expectedContentType {
+				t.Fatalf("expected content type %s, got %s", testCase.expectedContentType, contentType)
+			}
+			decodeResponse(t, resp)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// apolloManifestFormat identifies an Apollo persisted query manifest.
+const apolloManifestFormat = "apollo-persisted-query-manifest"
+
+// PersistedDocuments is an allowlist of the operation documents a handler
+// executes. Documents are requested by their id, either sent as documentId
+// or as hash of an automatic persisted query. Query texts are only accepted
+// if they are part of the allowlist.
+type PersistedDocuments struct {
+	byID   map[string]string
+	byHash map[string]string
+}
+
+// NewPersistedDocuments returns an allowlist of the given documents, keyed by
+// their id.
+func NewPersistedDocuments(documents map[string]string) *PersistedDocuments {
+	d := &PersistedDocuments{
+		byID:   make(map[string]string, len(documents)),
+		byHash: make(map[string]string, len(documents)),
+	}
+	for id, document := range documents {
+		d.byID[id] = document
+		d.byHash[sha256Hex(document)] = document
+	}
+	return d
+}
+
+// ParsePersistedDocuments parses either a Relay persisted-queries.json file,
+// which maps ids to documents, or an Apollo persisted query manifest.
+func ParsePersistedDocuments(data []byte) (*PersistedDocuments, error) {
+	var manifest struct {
+		Format     string `json:"format"`
+		Version    int    `json:"version"`
+		Operations []struct {
+			ID   string `json:"id"`
+			Body string `json:"body"`
+		} `json:"operations"`
+	}
+	if err := json.Unmarshal(data, &manifest); err == nil && manifest.Format == apolloManifestFormat {
+		if manifest.Version != 1 {
+			return nil, fmt.Errorf("unsupported persisted query manifest version %d", manifest.Version)
+		}
+		documents := make(map[string]string, len(manifest.Operations))
+		for _, operation := range manifest.Operations {
+			documents[operation.ID] = operation.Body
+		}
+		return NewPersistedDocuments(documents), nil
+	}
+
+	documents := map[string]string{}
+	if err := json.Unmarshal(data, &documents); err != nil {
+		return nil, errors.New("persisted documents must either be a map of ids to documents or an Apollo persisted query manifest")
+	}
+	return NewPersistedDocuments(documents), nil
+}
+
+// LoadPersistedDocuments reads the persisted documents from the file at
+// path, see ParsePersistedDocuments.
+func LoadPersistedDocuments(path string) (*PersistedDocuments, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePersistedDocuments(data)
+}
+
+// Get returns the document with the given id.
+func (d *PersistedDocuments) Get(id string) (string, bool) {
+	if document, ok := d.byID[id]; ok {
+		return document, true
+	}
+	document, ok := d.byHash[id]
+	return document, ok
+}
+
+// resolve fills in the query of opts from the allowlist and rejects queries
+// that are not part of it.
+func (d *PersistedDocuments) resolve(opts *RequestOptions) error {
+	id := opts.DocumentID
+	if id == "" {
+		id, _, _ = persistedQueryExtension(opts)
+	}
+
+	if id != "" {
+		document, ok := d.Get(id)
+		if !ok {
+			return &PersistedQueryError{
+				Message: fmt.Sprintf("Persisted query '%s' not found in the persisted query list", id),
+				Code:    "PERSISTED_QUERY_NOT_IN_LIST",
+				Status:  http.StatusNotFound,
+			}
+		}
+		if opts.Query != "" && opts.Query != document {
+			return errPersistedQueryHashMismatch
+		}
+		opts.Query = document
+		return nil
+	}
+
+	if opts.Query != "" {
+		if _, ok := d.byHash[sha256Hex(opts.Query)]; !ok {
+			return errQueryNotInSafelist
+		}
+	}
+	return nil
+}
+
+func sha256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
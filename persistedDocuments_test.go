@@ -0,0 +1,154 @@
+package handler_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestPersistedDocuments(t *testing.T) {
+	query := "query HeroNameQuery { hero { name } }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+
+	expected := &graphql.Result{
+		Data: map[string]interface{}{
+			"hero": map[string]interface{}{
+				"name": "R2-D2",
+			},
+		},
+	}
+
+	relay, err := handler.ParsePersistedDocuments([]byte(fmt.Sprintf(`{"hero-name": %q}`, query)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	apollo, err := handler.ParsePersistedDocuments([]byte(fmt.Sprintf(`{
+		"format": "apollo-persisted-query-manifest",
+		"version": 1,
+		"operations": [{"id": %q, "name": "HeroNameQuery", "type": "query", "body": %q}]
+	}`, hash, query)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		documents      *handler.PersistedDocuments
+		body           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		"RelayByDocumentID": {
+			documents:      relay,
+			body:           `{"documentId": "hero-name"}`,
+			expectedStatus: fasthttp.StatusOK,
+		},
+		"RelayByHash": {
+			documents:      relay,
+			body:           fmt.Sprintf(`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": %q}}}`, hash),
+			expectedStatus: fasthttp.StatusOK,
+		},
+		"ApolloByHash": {
+			documents:      apollo,
+			body:           fmt.Sprintf(`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": %q}}}`, hash),
+			expectedStatus: fasthttp.StatusOK,
+		},
+		"ApolloByDocumentID": {
+			documents:      apollo,
+			body:           fmt.Sprintf(`{"documentId": %q}`, hash),
+			expectedStatus: fasthttp.StatusOK,
+		},
+		"RegisteredQuery": {
+			documents:      apollo,
+			body:           fmt.Sprintf(`{"query": %q}`, query),
+			expectedStatus: fasthttp.StatusOK,
+		},
+		"UnknownDocumentID": {
+			documents:      relay,
+			body:           `{"documentId": "unknown"}`,
+			expectedStatus: fasthttp.StatusNotFound,
+			expectedCode:   "PERSISTED_QUERY_NOT_IN_LIST",
+		},
+		"FreeFormQuery": {
+			documents:      relay,
+			body:           `{"query": "{ hero { id } }"}`,
+			expectedStatus: fasthttp.StatusBadRequest,
+			expectedCode:   "QUERY_NOT_IN_SAFELIST",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := handler.New(&handler.Config{
+				Schema:             &testutil.StarWarsSchema,
+				PersistedDocuments: testCase.documents,
+			})
+
+			req := fasthttp.AcquireRequest()
+			req.Header.SetHost("localhost")
+			req.Header.Set("Accept", "application/graphql-response+json")
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.SetContentType("application/json")
+			req.URI().SetPath("/graphql")
+			req.SetBodyString(testCase.body)
+			defer fasthttp.ReleaseRequest(req)
+
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode() != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, resp.StatusCode())
+			}
+
+			result := decodeResponse(t, resp)
+			if testCase.expectedCode == "" {
+				if !reflect.DeepEqual(result, expected) {
+					t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+				}
+				return
+			}
+			if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != testCase.expectedCode {
+				t.Fatalf("unexpected result %v", result)
+			}
+		})
+	}
+}
+
+func TestLoadPersistedDocuments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "persisted-documents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "persisted-queries.json")
+	if err := ioutil.WriteFile(path, []byte(`{"a": "{ a }"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	documents, err := handler.LoadPersistedDocuments(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document, ok := documents.Get("a"); !ok || document != "{ a }" {
+		t.Fatalf("expected document a, got %q", document)
+	}
+
+	if _, err := handler.ParsePersistedDocuments([]byte(`[]`)); err == nil {
+		t.Fatalf("expected error for invalid manifest")
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// PersistedQueryStore stores the query documents of automatic persisted
+// queries by the hex encoded SHA-256 hash of their text.
+type PersistedQueryStore interface {
+	Get(ctx context.Context, hash string) (query string, ok bool)
+	Put(ctx context.Context, hash string, query string)
+}
+
+// LRUPersistedQueryStore is an in-memory PersistedQueryStore that keeps a
+// limited number of the most recently used queries.
+type LRUPersistedQueryStore struct {
+	cache *lruCache
+}
+
+// NewLRUPersistedQueryStore returns a store holding at most capacity
+// queries.
+func NewLRUPersistedQueryStore(capacity int) *LRUPersistedQueryStore {
+	return &LRUPersistedQueryStore{cache: newLRUCache(capacity)}
+}
+
+func (s *LRUPersistedQueryStore) Get(ctx context.Context, hash string) (string, bool) {
+	query, ok := s.cache.Get(hash)
+	if !ok {
+		return "", false
+	}
+	return query.(string), true
+}
+
+func (s *LRUPersistedQueryStore) Put(ctx context.Context, hash string, query string) {
+	s.cache.Add(hash, query)
+}
+
+// PersistedQueryError reports a failed persisted query lookup. Its code is
+// exposed in the extensions of the formatted error, so clients are able to
+// react on it. Status is the HTTP status code of the response and defaults
+// to 200.
+type PersistedQueryError struct {
+	Message string
+	Code    string
+	Status  int
+}
+
+func (e *PersistedQueryError) Error() string {
+	return e.Message
+}
+
+func (e *PersistedQueryError) StatusCode() int {
+	if e.Status == 0 {
+		return http.StatusOK
+	}
+	return e.Status
+}
+
+func (e *PersistedQueryError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+var (
+	// ErrPersistedQueryNotFound is returned if a query is requested by a hash
+	// that is unknown to the store. Clients then retry with the query text.
+	ErrPersistedQueryNotFound = &PersistedQueryError{
+		Message: "PersistedQueryNotFound",
+		Code:    "PERSISTED_QUERY_NOT_FOUND",
+	}
+	// ErrPersistedQueryNotSupported is returned if a query is requested by a
+	// hash but no PersistedQueryStore is configured.
+	ErrPersistedQueryNotSupported = &PersistedQueryError{
+		Message: "PersistedQueryNotSupported",
+		Code:    "PERSISTED_QUERY_NOT_SUPPORTED",
+	}
+	errPersistedQueryVersion = &PersistedQueryError{
+		Message: "Unsupported persisted query version",
+		Code:    "BAD_USER_INPUT",
+		Status:  http.StatusBadRequest,
+	}
+	errPersistedQueryHashMismatch = &PersistedQueryError{
+		Message: "provided sha does not match query",
+		Code:    "BAD_USER_INPUT",
+		Status:  http.StatusBadRequest,
+	}
+	errQueryNotInSafelist = &PersistedQueryError{
+		Message: "The operation body was not found in the persisted query safelist",
+		Code:    "QUERY_NOT_IN_SAFELIST",
+		Status:  http.StatusBadRequest,
+	}
+)
+
+// persistedQueryExtension returns the hash and version of the persistedQuery
+// request extension, or false if the extension is missing.
+func persistedQueryExtension(opts *RequestOptions) (hash string, version float64, ok bool) {
+	extension, ok := opts.Extensions["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return "", 0, false
+	}
+	hash, _ = extension["sha256Hash"].(string)
+	version, _ = extension["version"].(float64)
+	return strings.ToLower(hash), version, true
+}
+
+// resolveQuery fills in the query of opts that has been requested by id or
+// hash. If an allowlist of persisted documents is configured, it supersedes
+// automatic persisted queries.
+func (h *Handler) resolveQuery(ctx context.Context, opts *RequestOptions) error {
+	if h.persistedDocuments != nil {
+		return h.persistedDocuments.resolve(opts)
+	}
+	return h.resolvePersistedQuery(ctx, opts)
+}
+
+// resolvePersistedQuery implements the automatic persisted queries protocol.
+// If opts only carries a hash, the query is looked up in the store. If it
+// carries both, the hash is verified and the query gets registered.
+func (h *Handler) resolvePersistedQuery(ctx context.Context, opts *RequestOptions) error {
+	hash, version, ok := persistedQueryExtension(opts)
+	if !ok {
+		return nil
+	}
+	if h.persistedQueryStore == nil {
+		if opts.Query == "" {
+			return ErrPersistedQueryNotSupported
+		}
+		return nil
+	}
+	if version != 1 {
+		return errPersistedQueryVersion
+	}
+
+	if opts.Query == "" {
+		query, ok := h.persistedQueryStore.Get(ctx, hash)
+		if !ok {
+			return ErrPersistedQueryNotFound
+		}
+		opts.Query = query
+		return nil
+	}
+
+	if sha256Hex(opts.Query) != hash {
+		return errPersistedQueryHashMismatch
+	}
+	h.persistedQueryStore.Put(ctx, hash, opts.Query)
+	return nil
+}
@@ -0,0 +1,136 @@
+package handler_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestPersistedQueries(t *testing.T) {
+	query := "query HeroNameQuery { hero { name } }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	extensions := fmt.Sprintf(`{"persistedQuery": {"version": 1, "sha256Hash": %q}}`, hash)
+
+	expected := &graphql.Result{
+		Data: map[string]interface{}{
+			"hero": map[string]interface{}{
+				"name": "R2-D2",
+			},
+		},
+	}
+	notFound := &graphql.Result{
+		Errors: []gqlerrors.FormattedError{{
+			Message:    "PersistedQueryNotFound",
+			Locations:  []location.SourceLocation{},
+			Extensions: map[string]interface{}{"code": "PERSISTED_QUERY_NOT_FOUND"},
+		}},
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:              &testutil.StarWarsSchema,
+		PersistedQueryStore: handler.NewLRUPersistedQueryStore(10),
+	})
+
+	result := servePersistedQuery(t, h, "", extensions)
+	if !reflect.DeepEqual(result, notFound) {
+		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(notFound, result))
+	}
+
+	result = servePersistedQuery(t, h, query, extensions)
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+	}
+
+	result = servePersistedQuery(t, h, "", extensions)
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+	}
+
+	t.Run("GET", func(t *testing.T) {
+		req := fasthttp.AcquireRequest()
+		req.Header.SetHost("localhost")
+		req.Header.SetMethod(fasthttp.MethodGet)
+		req.URI().SetPath("/graphql")
+		req.URI().SetQueryString("extensions=" + url.QueryEscape(extensions))
+		defer fasthttp.ReleaseRequest(req)
+
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+
+		if err := serve(h.ServeHTTP, req, resp); err != nil {
+			t.Fatal(err)
+		}
+		if result := decodeResponse(t, resp); !reflect.DeepEqual(result, expected) {
+			t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+		}
+	})
+
+	t.Run("HashMismatch", func(t *testing.T) {
+		result := servePersistedQuery(t, h, "{ hero { id } }", extensions)
+		if len(result.Errors) != 1 || result.Errors[0].Message != "provided sha does not match query" {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+
+	t.Run("NotSupported", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema: &testutil.StarWarsSchema,
+		})
+
+		result := servePersistedQuery(t, h, "", extensions)
+		if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != "PERSISTED_QUERY_NOT_SUPPORTED" {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+func TestLRUPersistedQueryStore(t *testing.T) {
+	ctx := context.Background()
+	store := handler.NewLRUPersistedQueryStore(2)
+	store.Put(ctx, "a", "{ a }")
+	store.Put(ctx, "b", "{ b }")
+	if _, ok := store.Get(ctx, "a"); !ok {
+		t.Fatalf("expected query a to be stored")
+	}
+	store.Put(ctx, "c", "{ c }")
+
+	if _, ok := store.Get(ctx, "b"); ok {
+		t.Fatalf("expected least recently used query b to be evicted")
+	}
+	for _, hash := range []string{"a", "c"} {
+		if _, ok := store.Get(ctx, hash); !ok {
+			t.Fatalf("expected query %s to be stored", hash)
+		}
+	}
+}
+
+func servePersistedQuery(t *testing.T, h *handler.Handler, query, extensions string) *graphql.Result {
+	req := fasthttp.AcquireRequest()
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.URI().SetPath("/graphql")
+	req.SetBodyString(fmt.Sprintf(`{"query": %q, "extensions": %s}`, query, extensions))
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	return decodeResponse(t, resp)
+}
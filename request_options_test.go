@@ -1,7 +1,9 @@
 package handler_test
 
 import (
+	"errors"
 	"io"
+	"net/http"
 	"reflect"
 	"strings"
 	"testing"
@@ -62,6 +64,15 @@ func TestRequestOptions(t *testing.T) {
 			`{ "query": "query RebelsShipsQuery { rebels { name } }" }`,
 			&handler.RequestOptions{},
 		))
+		t.Run("InvalidVariables", testFn(
+			"query={ a }&variables={",
+			"",
+			"",
+			&handler.RequestOptions{
+				Query:     "{ a }",
+				Variables: make(map[string]interface{}),
+			},
+		))
 	})
 
 	t.Run("POST", func(t *testing.T) {
@@ -84,9 +95,138 @@ func TestRequestOptions(t *testing.T) {
 				Query: "query RebelsShipsQuery { rebels { name } }",
 			},
 		))
+		t.Run("InvalidVariablesInJSON", testFn(
+			"",
+			"application/json",
+			`{"query": "{ a }", "variables": "{v: 1}"}`,
+			&handler.RequestOptions{
+				Query: "{ a }",
+			},
+		))
+		t.Run("InvalidJSON", testFn(
+			"",
+			"application/json",
+			`{"query": "{ a }"`,
+			&handler.RequestOptions{},
+		))
 	})
 }
 
+func TestParseRequestOptions(t *testing.T) {
+	cases := map[string]struct {
+		method        string
+		query         string
+		contentType   string
+		body          string
+		expected      *handler.RequestOptions
+		expectedParam string
+	}{
+		"VariablesAsString": {
+			method:      fasthttp.MethodPost,
+			contentType: "application/json",
+			body:        `{"query": "{ a }", "variables": "{\"v\": 1}"}`,
+			expected: &handler.RequestOptions{
+				Query:     "{ a }",
+				Variables: map[string]interface{}{"v": float64(1)},
+			},
+		},
+		"InvalidBody": {
+			method:        fasthttp.MethodPost,
+			contentType:   "application/json",
+			body:          `{"query": "{ a }"`,
+			expectedParam: "",
+		},
+		"InvalidVariables": {
+			method:        fasthttp.MethodPost,
+			contentType:   "application/json",
+			body:          `{"query": "{ a }", "variables": "{v: 1}"}`,
+			expectedParam: "variables",
+		},
+		"InvalidExtensions": {
+			method:        fasthttp.MethodPost,
+			contentType:   "application/json",
+			body:          `{"query": "{ a }", "extensions": [1]}`,
+			expectedParam: "extensions",
+		},
+		"InvalidVariablesInQueryString": {
+			method:        fasthttp.MethodGet,
+			query:         "query={ a }&variables={",
+			expectedParam: "variables",
+		},
+		"InvalidVariablesInForm": {
+			method:        fasthttp.MethodPost,
+			contentType:   "application/x-www-form-urlencoded",
+			body:          "query={ a }&variables=[",
+			expectedParam: "variables",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fasthttp.Request{}
+			req.Header.SetMethod(tc.method)
+			req.URI().SetPath("/graphql")
+			req.URI().SetQueryString(tc.query)
+			req.Header.SetContentType(tc.contentType)
+			req.SetBodyString(tc.body)
+
+			result, err := handler.ParseRequestOptions(req)
+			if tc.expected != nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(result, tc.expected) {
+					t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(tc.expected, result))
+				}
+				return
+			}
+
+			var parseErr *handler.RequestParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a RequestParseError, got %v", err)
+			}
+			if parseErr.Param != tc.expectedParam {
+				t.Fatalf("expected param %q, got %q", tc.expectedParam, parseErr.Param)
+			}
+		})
+	}
+}
+
+func TestHandler_InvalidRequest(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		Batching: true,
+	})
+
+	for name, body := range map[string]string{
+		"Single": `{"query": "{ hero { name } }", "variables": "{"}`,
+		"Batch":  `[{"query": "{ hero { name } }"}, {"query": "{ hero { name } }", "variables": 1}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetHost("localhost")
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.SetContentType("application/json")
+			req.URI().SetPath("/graphql")
+			req.SetBodyString(body)
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if code := resp.StatusCode(); code != http.StatusBadRequest {
+				t.Fatalf("unexpected server response %v", code)
+			}
+			result := decodeResponse(t, resp)
+			if len(result.Errors) != 1 || !strings.HasPrefix(result.Errors[0].Message, "Invalid variables parameter: ") {
+				t.Fatalf("unexpected result %v", result)
+			}
+		})
+	}
+}
+
 // func TestRequestOptions_POST_ContentTypeApplicationGraphQL_WithNonGraphQLQueryContent(t *testing.T) {
 // 	body := []byte(`not a graphql query`)
 // 	expected := &RequestOptions{
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+)
+
+// acceptsEventStream returns true if r asks for a Server-Sent Events
+// response.
+func acceptsEventStream(r *fasthttp.Request) bool {
+	return strings.Contains(string(r.Header.Peek("Accept")), ContentTypeEventStream)
+}
+
+// serveEventStream executes opts and streams the results as Server-Sent
+// Events following the distinct connections mode of the GraphQL over SSE
+// protocol, see https://github.com/enisdenjo/graphql-sse/blob/master/PROTOCOL.md
+//
+// Every result is sent as a next event, the end of the stream is signalled
+// by a complete event. Comments are sent in between to keep the connection
+// alive and to detect clients that went away, in which case the operation
+// gets cancelled.
+func (h *Handler) serveEventStream(reqCtx *fasthttp.RequestCtx, opts *RequestOptions) {
+	// failed resolutions and invalid documents are reported by the stream
+	if !reqCtx.Request.Header.IsPost() && h.resolveQuery(reqCtx, opts) == nil {
+		if doc, errs := h.document(reqCtx, opts.Query); errs == nil {
+			if err := h.checkMethod(reqCtx, doc, opts.OperationName); err != nil {
+				h.writeJSON(reqCtx, statusCodeOf(err), errorResult(err))
+				return
+			}
+		}
+	}
+
+	var root map[string]interface{}
+	if h.rootObjectFn != nil {
+		root = h.rootObjectFn(reqCtx)
+	}
+	detached := detachedContext(reqCtx)
+
+	interval := h.keepAliveInterval
+	if interval <= 0 {
+		interval = defaultKeepAliveInterval
+	}
+
+	reqCtx.SetStatusCode(fasthttp.StatusOK)
+	reqCtx.Response.Header.SetContentType(ContentTypeEventStream + "; charset=utf-8")
+	reqCtx.Response.Header.Set("Cache-Control", "no-cache")
+	reqCtx.Response.Header.Set("X-Accel-Buffering", "no")
+
+	reqCtx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(detached)
+		defer cancel()
+
+		payloads := make(chan []byte)
+		go func() {
+			defer close(payloads)
+			h.streamOperation(ctx, opts, root, func(result *graphql.Result, payload []byte) bool {
+				select {
+				case payloads <- payload:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+		}()
+
+		// flush the headers right away, so clients know the stream has started
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(interval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case payload, ok := <-payloads:
+				if !ok {
+					w.WriteString("event: complete\ndata:\n\n")
+					w.Flush()
+					return
+				}
+				w.WriteString("event: next\ndata: ")
+				w.Write(payload)
+				w.WriteString("\n\n")
+			case <-keepAlive.C:
+				w.WriteString(":\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
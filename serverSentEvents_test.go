@@ -0,0 +1,147 @@
+package handler_test
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestServerSentEvents(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        &countdownSchema,
+		Subscriptions: true,
+	})
+
+	t.Run("Subscription", func(t *testing.T) {
+		req := fasthttp.AcquireRequest()
+		req.Header.SetHost("localhost")
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.SetContentType("application/json")
+		req.URI().SetPath("/graphql")
+		req.SetBodyString(`{"query": "subscription { countdown(from: 1) }"}`)
+		defer fasthttp.ReleaseRequest(req)
+
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+
+		if err := serve(h.ServeHTTP, req, resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if code := resp.StatusCode(); code != http.StatusOK {
+			t.Fatalf("unexpected server response %v", code)
+		}
+		if contentType := string(resp.Header.ContentType()); contentType != "text/event-stream; charset=utf-8" {
+			t.Fatalf("wrong content type, got %s", contentType)
+		}
+		expected := "event: next\ndata: {\"data\":{\"countdown\":1}}\n\n" +
+			"event: next\ndata: {\"data\":{\"countdown\":0}}\n\n" +
+			"event: complete\ndata:\n\n"
+		if body := string(resp.Body()); body != expected {
+			t.Fatalf("wrong body, expected %q, got %q", expected, body)
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		req := fasthttp.AcquireRequest()
+		req.Header.SetHost("localhost")
+		req.Header.SetMethod(fasthttp.MethodGet)
+		req.Header.Set("Accept", "text/event-stream")
+		req.URI().SetPath("/graphql")
+		req.URI().SetQueryString("query={name}")
+		defer fasthttp.ReleaseRequest(req)
+
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+
+		if err := serve(h.ServeHTTP, req, resp); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "event: next\ndata: {\"data\":{\"name\":\"countdown\"}}\n\n" +
+			"event: complete\ndata:\n\n"
+		if body := string(resp.Body()); body != expected {
+			t.Fatalf("wrong body, expected %q, got %q", expected, body)
+		}
+	})
+}
+
+func TestServerSentEvents_KeepAliveAndDisconnect(t *testing.T) {
+	cancelled := make(chan struct{})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+		Subscription: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Subscription",
+			Fields: graphql.Fields{
+				"never": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						events := make(chan interface{})
+						go func() {
+							<-p.Context.Done()
+							close(cancelled)
+						}()
+						return events, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:            &schema,
+		Subscriptions:     true,
+		KeepAliveInterval: 10 * time.Millisecond,
+	})
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, h.ServeHTTP)
+
+	c, err := ln.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost/graphql?query=subscription{never}", nil)
+	request.Header.Set("Accept", "text/event-stream")
+	if err := request.Write(c); err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.ReadResponse(bufio.NewReader(c), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(response.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, ":") {
+		t.Fatalf("expected keep-alive comment, got %q", line)
+	}
+
+	c.Close()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("operation was not cancelled after the client disconnected")
+	}
+}
@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// staticCacheControlVersioned lets clients keep assets requested with
+	// the current static version for a year without revalidating them.
+	staticCacheControlVersioned = "public, max-age=31536000, immutable"
+	// staticCacheControl makes clients revalidate other asset requests via
+	// their ETag.
+	staticCacheControl = "no-cache"
+)
+
+//go:embed static
+var embeddedStatic embed.FS
+
+// StaticFS returns the static assets of GraphiQL and Playground embedded in
+// the package. A replacement set in the Config must provide the same paths,
+// e.g. graphiql/graphiql.min.js or playground/middleware.js.
+func StaticFS() fs.FS {
+	static, _ := fs.Sub(embeddedStatic, "static")
+	return static
+}
+
+// staticAsset is a static asset of the IDEs along with its precomputed
+// representations.
+type staticAsset struct {
+	contentType string
+	identity    staticRepresentation
+	// encoded holds the compressed representations in the order of
+	// preference.
+	encoded []staticRepresentation
+}
+
+type staticRepresentation struct {
+	encoding string
+	content  []byte
+	etag     string
+}
+
+// staticEncoders are the content codings the static assets are compressed
+// with, in the order of preference.
+var staticEncoders = []struct {
+	encoding string
+	encode   func(w io.Writer) io.WriteCloser
+}{
+	{"br", func(w io.Writer) io.WriteCloser {
+		// the best compression would delay the startup by seconds
+		return brotli.NewWriterLevel(w, 9)
+	}},
+	{"gzip", func(w io.Writer) io.WriteCloser {
+		writer, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return writer
+	}},
+}
+
+var (
+	embeddedAssetsOnce sync.Once
+	embeddedAssets     map[string]*staticAsset
+)
+
+// embeddedStaticAssets returns the embedded static assets by path, loading,
+// hashing and compressing them on first use.
+func embeddedStaticAssets() map[string]*staticAsset {
+	embeddedAssetsOnce.Do(func() {
+		assets, err := loadStaticAssets(StaticFS())
+		if err != nil {
+			panic(err)
+		}
+		embeddedAssets = assets
+	})
+	return embeddedAssets
+}
+
+// loadStaticAssets reads all files of fsys and returns them by path along
+// with their precomputed representations.
+func loadStaticAssets(fsys fs.FS) (map[string]*staticAsset, error) {
+	assets := map[string]*staticAsset{}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		assets[name] = newStaticAsset(name, content)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+func newStaticAsset(name string, content []byte) *staticAsset {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+
+	hash := sha256.Sum256(content)
+	tag := hex.EncodeToString(hash[:16])
+	asset := &staticAsset{
+		contentType: contentType,
+		identity: staticRepresentation{
+			content: content,
+			etag:    strconv.Quote(tag),
+		},
+	}
+
+	for _, encoder := range staticEncoders {
+		var buffer bytes.Buffer
+		writer := encoder.encode(&buffer)
+		writer.Write(content)
+		writer.Close()
+		// only keep the compressed representation if it pays off
+		if buffer.Len() < len(content) {
+			asset.encoded = append(asset.encoded, staticRepresentation{
+				encoding: encoder.encoding,
+				content:  buffer.Bytes(),
+				etag:     strconv.Quote(tag + "-" + encoder.encoding),
+			})
+		}
+	}
+	return asset
+}
+
+// negotiate returns the representation of the asset that suits the
+// Accept-Encoding header best.
+func (a *staticAsset) negotiate(acceptEncoding string) *staticRepresentation {
+	selected, quality := &a.identity, 0.0
+	for i := range a.encoded {
+		if q := encodingQuality(acceptEncoding, a.encoded[i].encoding); q > quality {
+			selected, quality = &a.encoded[i], q
+		}
+	}
+	return selected
+}
+
+// staticVersionOf returns a hash over the content of all assets. It's
+// added to the asset URLs of the IDEs, so they change whenever an asset
+// does.
+func staticVersionOf(assets map[string]*staticAsset) string {
+	names := make([]string, 0, len(assets))
+	for name := range assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		hash.Write([]byte(name))
+		hash.Write([]byte(assets[name].identity.etag))
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// staticPathOf returns the path below which the static assets are served
+// for basePath, which may also be an absolute URL.
+func staticPathOf(basePath string) string {
+	if u, err := url.Parse(basePath); err == nil && u.Host != "" {
+		basePath = u.Path
+	}
+	if !strings.HasSuffix(basePath, "/") {
+		basePath += "/"
+	}
+	return basePath + "static/"
+}
+
+// isStaticRequest returns true if r asks for a path below the static path.
+// The handler doesn't serve static assets if neither an IDE nor StaticFS is
+// configured.
+func (h *Handler) isStaticRequest(r *fasthttp.Request) bool {
+	return h.staticAssets != nil && strings.HasPrefix(string(r.URI().Path()), h.staticPath)
+}
+
+func (h *Handler) serveStatic(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() && !ctx.IsHead() {
+		ctx.Response.Header.Set("Allow", "GET, HEAD")
+		ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	asset, ok := h.staticAssets[strings.TrimPrefix(string(ctx.Path()), h.staticPath)]
+	if !ok {
+		ctx.SetStatusCode(fasthttp.StatusNotFound)
+		return
+	}
+
+	representation := asset.negotiate(string(ctx.Request.Header.Peek("Accept-Encoding")))
+	if len(asset.encoded) > 0 {
+		ctx.Response.Header.Set("Vary", "Accept-Encoding")
+	}
+
+	ctx.Response.Header.SetContentType(asset.contentType)
+	if string(ctx.QueryArgs().Peek("v")) == h.staticVersion {
+		ctx.Response.Header.Set("Cache-Control", staticCacheControlVersioned)
+	} else {
+		ctx.Response.Header.Set("Cache-Control", staticCacheControl)
+	}
+	ctx.Response.Header.Set("ETag", representation.etag)
+
+	if matchesETag(string(ctx.Request.Header.Peek("If-None-Match")), representation.etag) {
+		ctx.SetStatusCode(fasthttp.StatusNotModified)
+		return
+	}
+	if representation.encoding != "" {
+		ctx.Response.Header.Set("Content-Encoding", representation.encoding)
+	}
+	ctx.Response.SetBody(representation.content)
+}
+
+// encodingQuality returns the quality the Accept-Encoding header assigns to
+// coding, which is zero if it isn't accepted.
+func encodingQuality(acceptEncoding, coding string) float64 {
+	quality := 0.0
+	for _, element := range strings.Split(acceptEncoding, ",") {
+		parameters := strings.Split(element, ";")
+		name := strings.ToLower(strings.TrimSpace(parameters[0]))
+		if name != coding && name != "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, parameter := range parameters[1:] {
+			parameter = strings.TrimSpace(parameter)
+			if strings.HasPrefix(parameter, "q=") {
+				if value, err := strconv.ParseFloat(parameter[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		// an explicit entry for coding takes precedence over the wildcard
+		if name == coding {
+			return q
+		}
+		quality = q
+	}
+	return quality
+}
+
+// matchesETag returns true if the If-None-Match header matches etag, using
+// the weak comparison of RFC 7232 section 2.3.2.
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
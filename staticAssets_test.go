@@ -0,0 +1,236 @@
+package handler_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+func TestServeStatic(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		GraphiQL: true,
+	})
+
+	plain := serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/graphiql.css", nil)
+	if code := plain.StatusCode(); code != http.StatusOK {
+		t.Fatalf("unexpected server response %v", code)
+	}
+	if contentType := string(plain.Header.ContentType()); contentType != "text/css; charset=utf-8" {
+		t.Fatalf("unexpected content type %q", contentType)
+	}
+	if cacheControl := string(plain.Header.Peek("Cache-Control")); cacheControl != "no-cache" {
+		t.Fatalf("unexpected cache control %q", cacheControl)
+	}
+	if vary := string(plain.Header.Peek("Vary")); vary != "Accept-Encoding" {
+		t.Fatalf("unexpected vary %q", vary)
+	}
+	if encoding := plain.Header.Peek("Content-Encoding"); len(encoding) != 0 {
+		t.Fatalf("unexpected content encoding %q", encoding)
+	}
+	etag := string(plain.Header.Peek("ETag"))
+	if len(etag) < 2 || etag[0] != '"' {
+		t.Fatalf("expected strong etag, got %q", etag)
+	}
+
+	t.Run("Encodings", func(t *testing.T) {
+		testCases := map[string]struct {
+			acceptEncoding   string
+			expectedEncoding string
+			decode           func(r io.Reader) (io.Reader, error)
+		}{
+			"Brotli": {
+				acceptEncoding:   "gzip, deflate, br",
+				expectedEncoding: "br",
+				decode:           func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+			},
+			"Gzip": {
+				acceptEncoding:   "br;q=0.5, gzip;q=0.8",
+				expectedEncoding: "gzip",
+				decode:           func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+			},
+			"Wildcard": {
+				acceptEncoding:   "*, br;q=0",
+				expectedEncoding: "gzip",
+				decode:           func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+			},
+			"Identity": {
+				acceptEncoding: "br;q=0, gzip;q=0",
+			},
+		}
+
+		for name, testCase := range testCases {
+			t.Run(name, func(t *testing.T) {
+				resp := serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/graphiql.css", map[string]string{
+					"Accept-Encoding": testCase.acceptEncoding,
+				})
+				if encoding := string(resp.Header.Peek("Content-Encoding")); encoding != testCase.expectedEncoding {
+					t.Fatalf("unexpected content encoding %q", encoding)
+				}
+				if testCase.decode == nil {
+					return
+				}
+				if encodedETag := string(resp.Header.Peek("ETag")); encodedETag == etag {
+					t.Fatalf("expected etag of encoded representation to differ from %q", etag)
+				}
+				reader, err := testCase.decode(bytes.NewReader(resp.Body()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				content, err := ioutil.ReadAll(reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(content, plain.Body()) {
+					t.Fatal("expected decoded body to equal the plain body")
+				}
+			})
+		}
+	})
+
+	t.Run("Versioned", func(t *testing.T) {
+		version := renderedStaticVersion(t, h)
+		resp := serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/graphiql.css?v="+version, nil)
+		if cacheControl := string(resp.Header.Peek("Cache-Control")); cacheControl != "public, max-age=31536000, immutable" {
+			t.Fatalf("unexpected cache control %q", cacheControl)
+		}
+
+		resp = serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/graphiql.css?v=outdated", nil)
+		if cacheControl := string(resp.Header.Peek("Cache-Control")); cacheControl != "no-cache" {
+			t.Fatalf("unexpected cache control %q", cacheControl)
+		}
+	})
+
+	t.Run("NotModified", func(t *testing.T) {
+		resp := serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/graphiql.css", map[string]string{
+			"If-None-Match": `"other", W/` + etag,
+		})
+		if code := resp.StatusCode(); code != http.StatusNotModified {
+			t.Fatalf("unexpected server response %v", code)
+		}
+		if len(resp.Body()) != 0 {
+			t.Fatalf("unexpected body %q", resp.Body())
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		resp := serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/missing.js", nil)
+		if code := resp.StatusCode(); code != http.StatusNotFound {
+			t.Fatalf("unexpected server response %v", code)
+		}
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		resp := serveStaticAsset(t, h, fasthttp.MethodPost, "/static/graphiql/graphiql.css", nil)
+		if code := resp.StatusCode(); code != http.StatusMethodNotAllowed {
+			t.Fatalf("unexpected server response %v", code)
+		}
+		if allow := string(resp.Header.Peek("Allow")); allow != "GET, HEAD" {
+			t.Fatalf("unexpected allow header %q", allow)
+		}
+	})
+
+	t.Run("Unanchored", func(t *testing.T) {
+		resp := serveStaticAsset(t, h, fasthttp.MethodGet, "/graphql/foo/static/graphiql/graphiql.css", nil)
+		if contentType := string(resp.Header.ContentType()); contentType == "text/css; charset=utf-8" {
+			t.Fatal("expected unanchored path not to be served as static asset")
+		}
+	})
+}
+
+func TestServeStatic_BasePath(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:     &testutil.StarWarsSchema,
+		Playground: true,
+		BasePath:   "/admin/",
+	})
+
+	resp := serveStaticAsset(t, h, fasthttp.MethodGet, "/admin/static/playground/index.css", nil)
+	if code := resp.StatusCode(); code != http.StatusOK {
+		t.Fatalf("unexpected server response %v", code)
+	}
+
+	resp = serveStaticAsset(t, h, fasthttp.MethodGet, "/static/playground/index.css", nil)
+	if contentType := string(resp.Header.ContentType()); contentType == "text/css; charset=utf-8" {
+		t.Fatal("expected path outside of base path not to be served as static asset")
+	}
+}
+
+func serveStaticAsset(t *testing.T, h *handler.Handler, method, path string, headers map[string]string) *fasthttp.Response {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(method)
+	req.Header.Set("Accept", "*/*")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req.SetRequestURI(path)
+	resp := &fasthttp.Response{}
+
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServeStatic_CustomFS(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		GraphiQL: true,
+		StaticFS: fstest.MapFS{
+			"graphiql/theme.css": &fstest.MapFile{Data: []byte("body { color: red; }")},
+			"graphiql/LICENSE":   &fstest.MapFile{Data: []byte("MIT")},
+		},
+	})
+
+	resp := serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/theme.css", nil)
+	if code := resp.StatusCode(); code != http.StatusOK {
+		t.Fatalf("unexpected server response %v", code)
+	}
+	if body := string(resp.Body()); body != "body { color: red; }" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	resp = serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/LICENSE", nil)
+	if contentType := string(resp.Header.ContentType()); contentType != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected content type %q", contentType)
+	}
+
+	resp = serveStaticAsset(t, h, fasthttp.MethodGet, "/static/graphiql/graphiql.css", nil)
+	if code := resp.StatusCode(); code != http.StatusNotFound {
+		t.Fatalf("unexpected server response %v", code)
+	}
+
+	embedded := handler.New(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		GraphiQL: true,
+	})
+	if renderedStaticVersion(t, h) == renderedStaticVersion(t, embedded) {
+		t.Fatal("expected the asset URLs to change along with the assets")
+	}
+}
+
+var staticVersionPattern = regexp.MustCompile(`graphiql\.css\?v=([0-9a-f]+)`)
+
+// renderedStaticVersion returns the version GraphiQL rendered by h requests
+// its assets with.
+func renderedStaticVersion(t *testing.T, h *handler.Handler) string {
+	resp := serveStaticAsset(t, h, fasthttp.MethodGet, "/graphql", map[string]string{"Accept": "text/html"})
+	match := staticVersionPattern.FindSubmatch(resp.Body())
+	if match == nil {
+		t.Fatalf("expected versioned asset URL in %s", resp.Body())
+	}
+	return string(match[1])
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/valyala/fasthttp"
+)
+
+// statusError is an error of the request itself that is reported with the
+// given HTTP status code.
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+func (e *statusError) StatusCode() int {
+	return e.status
+}
+
+var (
+	errMutationOverGET = &statusError{
+		status:  fasthttp.StatusMethodNotAllowed,
+		message: "Can only perform a mutation operation from a POST request.",
+	}
+	errSubscriptionOverGET = &statusError{
+		status:  fasthttp.StatusMethodNotAllowed,
+		message: "Can only perform a subscription operation from a POST request.",
+	}
+	errBatchingDisabled = &statusError{
+		status:  fasthttp.StatusBadRequest,
+		message: "Batching is not enabled.",
+	}
+	errUnsupportedContentType = &statusError{
+		status:  fasthttp.StatusUnsupportedMediaType,
+		message: "Unsupported content type, use " + ContentTypeJSON + ".",
+	}
+)
+
+// statusCodeOf returns the HTTP status code err asks for, or 200 if it
+// doesn't specify one.
+func statusCodeOf(err error) int {
+	var coder interface{ StatusCode() int }
+	if errors.As(err, &coder) {
+		return coder.StatusCode()
+	}
+	return fasthttp.StatusOK
+}
+
+// resultStatusCode returns the HTTP status code of a response carrying
+// result. Results without data whose errors don't point into the response
+// report a request that failed to parse or validate, all other results are
+// execution results.
+func resultStatusCode(result *graphql.Result) int {
+	if result.Data != nil || !result.HasErrors() {
+		return fasthttp.StatusOK
+	}
+	for _, err := range result.Errors {
+		if len(err.Path) > 0 {
+			return fasthttp.StatusOK
+		}
+	}
+	return fasthttp.StatusBadRequest
+}
+
+// checkContentType rejects POST requests with a body of a content type the
+// handler can't read.
+func (h *Handler) checkContentType(r *fasthttp.Request) error {
+	if !r.Header.IsPost() {
+		return nil
+	}
+
+	switch mediaTypeOf(r) {
+	case "", ContentTypeJSON, ContentTypeGraphQL, ContentTypeFormURLEncoded:
+		return nil
+	case ContentTypeMultipartFormData:
+		if h.uploads {
+			return nil
+		}
+	}
+	return errUnsupportedContentType
+}
+
+// mediaTypeOf returns the content type of r without parameters.
+func mediaTypeOf(r *fasthttp.Request) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(string(r.Header.ContentType()), ";")[0]))
+}
+
+// checkMethod refuses mutations that are not sent via POST, so they can't
+// be triggered by simple links or images. Subscriptions are refused as well
+// if configured. The Allow header of the response is set accordingly.
+func (h *Handler) checkMethod(reqCtx *fasthttp.RequestCtx, doc *ast.Document, operationName string) error {
+	if reqCtx.Request.Header.IsPost() {
+		return nil
+	}
+
+	operation := selectOperation(doc, operationName)
+	if operation == nil {
+		return nil
+	}
+
+	var refusal error
+	switch {
+	case operation.Operation == ast.OperationTypeMutation:
+		refusal = errMutationOverGET
+	case operation.Operation == ast.OperationTypeSubscription && h.forbidSubscriptionsOverGET:
+		refusal = errSubscriptionOverGET
+	default:
+		return nil
+	}
+	reqCtx.Response.Header.Set("Allow", fasthttp.MethodPost)
+	return refusal
+}
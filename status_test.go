@@ -0,0 +1,233 @@
+package handler_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+var statusSchema = func() graphql.Schema {
+	fail := func(p graphql.ResolveParams) (interface{}, error) {
+		return nil, errors.New("failed")
+	}
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return "status", nil
+					},
+				},
+				"nullable":    &graphql.Field{Type: graphql.String, Resolve: fail},
+				"nonNullable": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: fail},
+			},
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}()
+
+func TestStatusCodes(t *testing.T) {
+	testCases := map[string]struct {
+		method         string
+		contentType    string
+		queryString    string
+		body           string
+		expectedStatus int
+		// expectedJSONStatus is the status of application/json responses,
+		// if it differs from expectedStatus.
+		expectedJSONStatus int
+	}{
+		"Query": {
+			method:         fasthttp.MethodGet,
+			queryString:    "query={name}",
+			expectedStatus: http.StatusOK,
+		},
+		"ExecutionError": {
+			method:         fasthttp.MethodGet,
+			queryString:    "query={name nullable}",
+			expectedStatus: http.StatusOK,
+		},
+		"NonNullExecutionError": {
+			method:         fasthttp.MethodGet,
+			queryString:    "query={nonNullable}",
+			expectedStatus: http.StatusOK,
+		},
+		"EmptyQuery": {
+			method:             fasthttp.MethodGet,
+			expectedStatus:     http.StatusBadRequest,
+			expectedJSONStatus: http.StatusOK,
+		},
+		"ParseError": {
+			method:             fasthttp.MethodPost,
+			contentType:        "application/graphql",
+			body:               "{ name",
+			expectedStatus:     http.StatusBadRequest,
+			expectedJSONStatus: http.StatusOK,
+		},
+		"ValidationError": {
+			method:             fasthttp.MethodPost,
+			contentType:        "application/json",
+			body:               `{"query": "{ unknown }"}`,
+			expectedStatus:     http.StatusBadRequest,
+			expectedJSONStatus: http.StatusOK,
+		},
+		"MalformedJSON": {
+			method:         fasthttp.MethodPost,
+			contentType:    "application/json",
+			body:           `{"query": `,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"UnknownOperation": {
+			method:             fasthttp.MethodPost,
+			contentType:        "application/json",
+			body:               `{"query": "query A { name }", "operationName": "B"}`,
+			expectedStatus:     http.StatusBadRequest,
+			expectedJSONStatus: http.StatusOK,
+		},
+		"MutationOverGET": {
+			method:         fasthttp.MethodGet,
+			queryString:    "query=mutation{name}",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		"UnsupportedContentType": {
+			method:         fasthttp.MethodPost,
+			contentType:    "text/plain",
+			body:           `{"query": "{ name }"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for _, mode := range []struct {
+				accept string
+				legacy bool
+			}{
+				{accept: "application/graphql-response+json"},
+				{accept: "application/json"},
+				{accept: "application/json", legacy: true},
+			} {
+				legacy := mode.legacy
+				h := handler.New(&handler.Config{
+					Schema:            &statusSchema,
+					LegacyStatusCodes: legacy,
+				})
+
+				req := fasthttp.AcquireRequest()
+				req.Header.SetHost("localhost")
+				req.Header.Set("Accept", mode.accept)
+				req.Header.SetMethod(testCase.method)
+				req.Header.SetContentType(testCase.contentType)
+				req.URI().SetPath("/graphql")
+				req.URI().SetQueryString(testCase.queryString)
+				req.SetBodyString(testCase.body)
+
+				resp := fasthttp.AcquireResponse()
+
+				if err := serve(h.ServeHTTP, req, resp); err != nil {
+					t.Fatal(err)
+				}
+
+				expectedStatus := testCase.expectedStatus
+				if mode.accept == "application/json" && testCase.expectedJSONStatus != 0 {
+					expectedStatus = testCase.expectedJSONStatus
+				}
+				if legacy && expectedStatus != http.StatusMethodNotAllowed {
+					expectedStatus = http.StatusOK
+				}
+				if code := resp.StatusCode(); code != expectedStatus {
+					t.Fatalf("expected status %d (%s, legacy %t), got %d: %s", expectedStatus, mode.accept, legacy, code, resp.Body())
+				}
+				decodeResponse(t, resp)
+
+				fasthttp.ReleaseRequest(req)
+				fasthttp.ReleaseResponse(resp)
+			}
+		})
+	}
+}
+
+func TestForbidOperationsOverGET(t *testing.T) {
+	testCases := map[string]struct {
+		forbidSubscriptions bool
+		query               string
+		accept              string
+		expectedStatus      int
+	}{
+		"Query": {
+			query:          "{ name }",
+			expectedStatus: http.StatusOK,
+		},
+		"Mutation": {
+			query:          "mutation { name }",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		"SelectedMutation": {
+			query:          "query A { name } mutation B { name }&operationName=B",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		"MutationAsEventStream": {
+			query:          "mutation { name }",
+			accept:         "text/event-stream",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		"Subscription": {
+			forbidSubscriptions: true,
+			query:               "subscription { countdown(from: 1) }",
+			accept:              "text/event-stream",
+			expectedStatus:      http.StatusMethodNotAllowed,
+		},
+		"SubscriptionAllowed": {
+			query:          "subscription { countdown(from: 1) }",
+			accept:         "text/event-stream",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := handler.New(&handler.Config{
+				Schema:                     &countdownSchema,
+				Subscriptions:              true,
+				ForbidSubscriptionsOverGET: testCase.forbidSubscriptions,
+			})
+
+			req := fasthttp.AcquireRequest()
+			req.Header.SetHost("localhost")
+			req.Header.SetMethod(fasthttp.MethodGet)
+			req.Header.Set("Accept", testCase.accept)
+			req.URI().SetPath("/graphql")
+			req.URI().SetQueryString("query=" + testCase.query)
+			defer fasthttp.ReleaseRequest(req)
+
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if code := resp.StatusCode(); code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatus, code, resp.Body())
+			}
+			if testCase.expectedStatus != http.StatusMethodNotAllowed {
+				return
+			}
+			if allow := string(resp.Header.Peek("Allow")); allow != "POST" {
+				t.Fatalf("expected Allow header POST, got %q", allow)
+			}
+			if result := decodeResponse(t, resp); result.Data != nil || len(result.Errors) != 1 {
+				t.Fatalf("unexpected result %v", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/valyala/fasthttp"
+)
+
+// subscriptionSchemas holds the two schemas derived from a schema's
+// subscription type. The source schema resolves the root field into its
+// event stream, the event schema completes a single event against the
+// selection set of the operation.
+type subscriptionSchemas struct {
+	source graphql.Schema
+	event  graphql.Schema
+}
+
+// subscriptionEventKey is the context key under which the current source
+// event is handed to the root field of the event schema.
+type subscriptionEventKey struct{}
+
+var subscriptionSchemaCache sync.Map
+
+// streamScalar passes the value returned by a subscription root field
+// through execution untouched, so the event stream can be picked up from
+// the result.
+var streamScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name: "SubscriptionStream",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+})
+
+// subscribe executes the operation of doc described by p. Queries and
+// mutations produce exactly one result. For subscriptions, the resolver of
+// the selected root field must return a channel; every value received from
+// it is completed against the operation's selection set and delivered as a
+// separate result. The returned channel is closed once the event stream is
+// closed or p.Context is done.
+func subscribe(p graphql.Params, doc *ast.Document) chan *graphql.Result {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+
+	executeParams := graphql.ExecuteParams{
+		Schema:        p.Schema,
+		Root:          p.RootObject,
+		AST:           doc,
+		OperationName: p.OperationName,
+		Args:          p.VariableValues,
+		Context:       p.Context,
+	}
+
+	if operation := selectOperation(doc, p.OperationName); operation == nil || operation.Operation != ast.OperationTypeSubscription {
+		return singleResult(graphql.Execute(executeParams))
+	}
+
+	schemas, err := subscriptionSchemasFor(&p.Schema)
+	if err != nil {
+		return singleResult(&graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+	}
+
+	executeParams.Schema = schemas.source
+	sourceResult := graphql.Execute(executeParams)
+	if sourceResult.HasErrors() {
+		return singleResult(&graphql.Result{Errors: sourceResult.Errors})
+	}
+	stream, err := eventStream(sourceResult)
+	if err != nil {
+		return singleResult(&graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+	}
+
+	executeParams.Schema = schemas.event
+	results := make(chan *graphql.Result, 1)
+	go func() {
+		defer close(results)
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(p.Context.Done())},
+			{Dir: reflect.SelectRecv, Chan: stream},
+		}
+		for {
+			chosen, event, ok := reflect.Select(cases)
+			if chosen == 0 || !ok {
+				return
+			}
+
+			eventParams := executeParams
+			eventParams.Context = context.WithValue(p.Context, subscriptionEventKey{}, event.Interface())
+			select {
+			case results <- graphql.Execute(eventParams):
+			case <-p.Context.Done():
+				return
+			}
+		}
+	}()
+	return results
+}
+
+// streamOperation executes opts and passes every result along with its JSON
+// encoding to send, until send returns false or no more results follow.
+// Errors are formatted and ResultCallbackFn is called the same way as for
+// operations served by ServeHTTP.
+func (h *Handler) streamOperation(ctx context.Context, opts *RequestOptions, root map[string]interface{}, send func(result *graphql.Result, payload []byte) bool) {
+	ctx, extensions := withExtensions(ctx, opts.Extensions)
+	params := graphql.Params{
+		Schema:         *h.schema(),
+		VariableValues: opts.Variables,
+		OperationName:  opts.OperationName,
+		Context:        ctx,
+		RootObject:     root,
+	}
+
+	var results chan *graphql.Result
+	if err := h.limits.checkQuery(opts); err != nil {
+		results = singleResult(errorResult(err))
+	} else if err := h.resolveQuery(ctx, opts); err != nil {
+		results = singleResult(errorResult(err))
+	} else if doc, errs := h.document(ctx, opts.Query); errs != nil {
+		results = singleResult(&graphql.Result{Errors: errs})
+	} else if extensions, err := h.checkComplexity(doc, opts.OperationName, opts.Variables); err != nil {
+		result := errorResult(err)
+		result.Extensions = extensions
+		results = singleResult(result)
+	} else {
+		params.RequestString = opts.Query
+		results = subscribe(params, doc)
+	}
+
+	for result := range results {
+		extensions.apply(result)
+		h.formatErrors(result)
+		payload, _ := json.Marshal(result)
+		if h.resultCallbackFn != nil {
+			h.resultCallbackFn(ctx, &params, result, payload)
+		}
+		if !send(result, payload) {
+			return
+		}
+	}
+}
+
+// singleResult returns a closed channel that delivers only result.
+func singleResult(result *graphql.Result) chan *graphql.Result {
+	results := make(chan *graphql.Result, 1)
+	results <- result
+	close(results)
+	return results
+}
+
+// userValuesContext exposes a snapshot of the user values of a request to
+// resolvers that run after the request context has been released.
+type userValuesContext struct {
+	context.Context
+	values map[string]interface{}
+}
+
+func (c *userValuesContext) Value(key interface{}) interface{} {
+	if keyString, ok := key.(string); ok {
+		if value, ok := c.values[keyString]; ok {
+			return value
+		}
+	}
+	return c.Context.Value(key)
+}
+
+// detachedContext returns a context carrying a snapshot of the user values
+// of reqCtx, which stays usable after the request handler has returned.
+func detachedContext(reqCtx *fasthttp.RequestCtx) context.Context {
+	values := map[string]interface{}{}
+	reqCtx.VisitUserValues(func(key []byte, value interface{}) {
+		values[string(key)] = value
+	})
+	return &userValuesContext{Context: context.Background(), values: values}
+}
+
+// selectOperation returns the operation of doc that is selected by name, or
+// nil if there is no such operation.
+func selectOperation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var selected *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if selected != nil {
+				return nil
+			}
+			selected = operation
+			continue
+		}
+		if operation.Name != nil && operation.Name.Value == name {
+			return operation
+		}
+	}
+	return selected
+}
+
+// eventStream picks the channel returned by the subscription root field from
+// the result of executing the source schema.
+func eventStream(result *graphql.Result) (reflect.Value, error) {
+	data, _ := result.Data.(map[string]interface{})
+	if len(data) != 1 {
+		return reflect.Value{}, errors.New("Subscription operations must select exactly one top level field.")
+	}
+	for field, value := range data {
+		stream := reflect.ValueOf(value)
+		if !stream.IsValid() || stream.Kind() != reflect.Chan || stream.Type().ChanDir()&reflect.RecvDir == 0 {
+			return reflect.Value{}, fmt.Errorf("Subscription field %q must resolve to a receivable channel, got %T.", field, value)
+		}
+		return stream, nil
+	}
+	panic("unreachable")
+}
+
+// subscriptionSchemasFor returns the subscription schemas derived from
+// schema, building and caching them on first use.
+func subscriptionSchemasFor(schema *graphql.Schema) (*subscriptionSchemas, error) {
+	subscriptionType := schema.SubscriptionType()
+	if subscriptionType == nil {
+		return nil, errors.New("Schema is not configured for subscriptions")
+	}
+	if cached, ok := subscriptionSchemaCache.Load(subscriptionType); ok {
+		return cached.(*subscriptionSchemas), nil
+	}
+
+	sourceFields := graphql.Fields{}
+	eventFields := graphql.Fields{}
+	for name, definition := range subscriptionType.Fields() {
+		args := graphql.FieldConfigArgument{}
+		for _, arg := range definition.Args {
+			args[arg.Name()] = &graphql.ArgumentConfig{
+				Type:         arg.Type,
+				DefaultValue: arg.DefaultValue,
+				Description:  arg.Description(),
+			}
+		}
+		sourceFields[name] = &graphql.Field{
+			Name:    name,
+			Type:    streamScalar,
+			Args:    args,
+			Resolve: definition.Resolve,
+		}
+		eventFields[name] = &graphql.Field{
+			Name:              name,
+			Type:              definition.Type,
+			Args:              args,
+			Description:       definition.Description,
+			DeprecationReason: definition.DeprecationReason,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Context.Value(subscriptionEventKey{}), nil
+			},
+		}
+	}
+
+	types := []graphql.Type{}
+	for name, t := range schema.TypeMap() {
+		if t != subscriptionType && !strings.HasPrefix(name, "__") {
+			types = append(types, t)
+		}
+	}
+
+	derive := func(fields graphql.Fields) (graphql.Schema, error) {
+		return graphql.NewSchema(graphql.SchemaConfig{
+			Query:    schema.QueryType(),
+			Mutation: schema.MutationType(),
+			Subscription: graphql.NewObject(graphql.ObjectConfig{
+				Name:   subscriptionType.Name(),
+				Fields: fields,
+			}),
+			Types:      types,
+			Directives: schema.Directives(),
+		})
+	}
+
+	sourceSchema, err := derive(sourceFields)
+	if err != nil {
+		return nil, err
+	}
+	eventSchema, err := derive(eventFields)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, _ := subscriptionSchemaCache.LoadOrStore(subscriptionType, &subscriptionSchemas{
+		source: sourceSchema,
+		event:  eventSchema,
+	})
+	return cached.(*subscriptionSchemas), nil
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// TracingHeader is the request header that asks for a trace of the
+// operation if Tracing is enabled.
+const TracingHeader = "X-Trace-Graphql"
+
+const tracerKey = "graphql-fasthttp-handler.tracer"
+
+// tracer records the timings of an operation in the Apollo tracing format,
+// see https://github.com/apollographql/apollo-tracing
+type tracer struct {
+	start time.Time
+
+	mu         sync.Mutex
+	parsing    tracingSpan
+	validation tracingSpan
+	resolvers  []*resolverTrace
+}
+
+type tracingSpan struct {
+	StartOffset int64 `json:"startOffset"`
+	Duration    int64 `json:"duration"`
+}
+
+type resolverTrace struct {
+	Path        []interface{} `json:"path"`
+	ParentType  string        `json:"parentType"`
+	FieldName   string        `json:"fieldName"`
+	ReturnType  string        `json:"returnType"`
+	StartOffset int64         `json:"startOffset"`
+	Duration    int64         `json:"duration"`
+}
+
+type tracingResult struct {
+	Version    int              `json:"version"`
+	StartTime  string           `json:"startTime"`
+	EndTime    string           `json:"endTime"`
+	Duration   int64            `json:"duration"`
+	Parsing    tracingSpan      `json:"parsing"`
+	Validation tracingSpan      `json:"validation"`
+	Execution  tracingExecution `json:"execution"`
+}
+
+type tracingExecution struct {
+	Resolvers []*resolverTrace `json:"resolvers"`
+}
+
+// withTracer returns a context in which the timings of the executed
+// operation are recorded.
+func withTracer(ctx context.Context) context.Context {
+	return withValue(ctx, tracerKey, &tracer{start: time.Now()})
+}
+
+// tracerFrom returns the tracer of ctx, or nil if the operation executed in
+// ctx isn't traced.
+func tracerFrom(ctx context.Context) *tracer {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(tracerKey).(*tracer)
+	return t
+}
+
+// startParsing starts timing the parsing and returns the function that
+// stops it. It's safe to be called on a nil tracer.
+func (t *tracer) startParsing() func() {
+	if t == nil {
+		return func() {}
+	}
+	return t.span(&t.parsing)
+}
+
+// startValidation starts timing the validation and returns the function
+// that stops it. It's safe to be called on a nil tracer.
+func (t *tracer) startValidation() func() {
+	if t == nil {
+		return func() {}
+	}
+	return t.span(&t.validation)
+}
+
+func (t *tracer) span(span *tracingSpan) func() {
+	start := time.Now()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		span.StartOffset = start.Sub(t.start).Nanoseconds()
+		span.Duration = time.Since(start).Nanoseconds()
+	}
+}
+
+func (t *tracer) result() *tracingResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	end := time.Now()
+	resolvers := t.resolvers
+	if resolvers == nil {
+		resolvers = []*resolverTrace{}
+	}
+	return &tracingResult{
+		Version:    1,
+		StartTime:  t.start.UTC().Format(time.RFC3339Nano),
+		EndTime:    end.UTC().Format(time.RFC3339Nano),
+		Duration:   end.Sub(t.start).Nanoseconds(),
+		Parsing:    t.parsing,
+		Validation: t.validation,
+		Execution:  tracingExecution{Resolvers: resolvers},
+	}
+}
+
+// tracingExtension reports the timings recorded by the tracer of the
+// context in the tracing extension of the result. It's only installed in
+// the schema operations are executed against if they are traced.
+type tracingExtension struct{}
+
+var _ graphql.Extension = tracingExtension{}
+
+func (tracingExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return ctx
+}
+
+func (tracingExtension) Name() string {
+	return "tracing"
+}
+
+func (tracingExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	stop := tracerFrom(ctx).startParsing()
+	return ctx, func(error) { stop() }
+}
+
+func (tracingExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	stop := tracerFrom(ctx).startValidation()
+	return ctx, func([]gqlerrors.FormattedError) { stop() }
+}
+
+func (tracingExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (tracingExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	t := tracerFrom(ctx)
+	if t == nil {
+		return ctx, func(interface{}, error) {}
+	}
+	start := time.Now()
+	return ctx, func(interface{}, error) {
+		trace := &resolverTrace{
+			Path:        info.Path.AsArray(),
+			FieldName:   info.FieldName,
+			StartOffset: start.Sub(t.start).Nanoseconds(),
+			Duration:    time.Since(start).Nanoseconds(),
+		}
+		if info.ParentType != nil {
+			trace.ParentType = info.ParentType.Name()
+		}
+		if info.ReturnType != nil {
+			trace.ReturnType = info.ReturnType.String()
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.resolvers = append(t.resolvers, trace)
+	}
+}
+
+func (tracingExtension) HasResult() bool {
+	return true
+}
+
+func (tracingExtension) GetResult(ctx context.Context) interface{} {
+	if t := tracerFrom(ctx); t != nil {
+		return t.result()
+	}
+	return nil
+}
+
+// tracingSchemaFor returns a copy of schema with the tracing extension
+// installed.
+func tracingSchemaFor(schema *graphql.Schema) *graphql.Schema {
+	traced := *schema
+	traced.AddExtensions(tracingExtension{})
+	return &traced
+}
@@ -0,0 +1,116 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+type tracingResponse struct {
+	Extensions struct {
+		Tracing *struct {
+			Version    int    `json:"version"`
+			StartTime  string `json:"startTime"`
+			EndTime    string `json:"endTime"`
+			Duration   int64  `json:"duration"`
+			Parsing    *struct{ StartOffset, Duration int64 }
+			Validation *struct{ StartOffset, Duration int64 }
+			Execution  struct {
+				Resolvers []struct {
+					Path       []interface{} `json:"path"`
+					ParentType string        `json:"parentType"`
+					FieldName  string        `json:"fieldName"`
+					ReturnType string        `json:"returnType"`
+				} `json:"resolvers"`
+			} `json:"execution"`
+		} `json:"tracing"`
+	} `json:"extensions"`
+}
+
+func TestTracing(t *testing.T) {
+	for name, config := range map[string]handler.Config{
+		"Default":       {Tracing: true},
+		"DocumentCache": {Tracing: true, DocumentCacheSize: 10},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config.Schema = &testutil.StarWarsSchema
+			h := handler.New(&config)
+
+			response := serveTracingQuery(t, h, true)
+			tracing := response.Extensions.Tracing
+			if tracing == nil {
+				t.Fatal("expected tracing extension")
+			}
+			if tracing.Version != 1 || tracing.StartTime == "" || tracing.EndTime == "" || tracing.Duration <= 0 {
+				t.Fatalf("unexpected trace %+v", tracing)
+			}
+			if tracing.Parsing == nil || tracing.Validation == nil {
+				t.Fatalf("expected parsing and validation timings, got %+v", tracing)
+			}
+
+			paths := [][]interface{}{}
+			for _, resolver := range tracing.Execution.Resolvers {
+				paths = append(paths, resolver.Path)
+				if resolver.FieldName == "hero" && (resolver.ParentType != "Query" || resolver.ReturnType != "Character") {
+					t.Fatalf("unexpected resolver trace %+v", resolver)
+				}
+			}
+			expected := [][]interface{}{{"hero"}, {"hero", "name"}}
+			if !reflect.DeepEqual(paths, expected) {
+				t.Fatalf("expected resolver paths %v, got %v", expected, paths)
+			}
+		})
+	}
+
+	t.Run("WithoutHeader", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema:  &testutil.StarWarsSchema,
+			Tracing: true,
+		})
+		if response := serveTracingQuery(t, h, false); response.Extensions.Tracing != nil {
+			t.Fatalf("unexpected tracing extension %+v", response.Extensions.Tracing)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema: &testutil.StarWarsSchema,
+		})
+		if response := serveTracingQuery(t, h, true); response.Extensions.Tracing != nil {
+			t.Fatalf("unexpected tracing extension %+v", response.Extensions.Tracing)
+		}
+	})
+}
+
+func serveTracingQuery(t *testing.T, h *handler.Handler, trace bool) *tracingResponse {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/graphql")
+	if trace {
+		req.Header.Set(handler.TracingHeader, "1")
+	}
+	req.URI().SetPath("/graphql")
+	req.SetBodyString("{ hero { name } }")
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	if err := serve(h.ServeHTTP, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if code := resp.StatusCode(); code != http.StatusOK {
+		t.Fatalf("unexpected server response %v: %s", code, resp.Body())
+	}
+	var response tracingResponse
+	if err := json.Unmarshal(resp.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return &response
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/valyala/fasthttp"
+)
+
+// Upload is a file sent along with an operation following the GraphQL
+// multipart request specification, see
+// https://github.com/jaydenseric/graphql-multipart-request-spec
+type Upload struct {
+	*multipart.FileHeader
+}
+
+// UploadScalar is the Upload type to use for arguments that receive files.
+// Resolvers get the files as *Upload values.
+var UploadScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Upload",
+	Description: "The `Upload` scalar type represents a file upload.",
+	Serialize: func(value interface{}) interface{} {
+		if upload, ok := value.(*Upload); ok {
+			return upload.Filename
+		}
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if upload, ok := value.(*Upload); ok {
+			return upload
+		}
+		return nil
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		return nil
+	},
+})
+
+// isMultipartRequest returns true if r has a multipart/form-data body.
+func isMultipartRequest(r *fasthttp.Request) bool {
+	return r.Header.IsPost() && mediaTypeOf(r) == ContentTypeMultipartFormData
+}
+
+// newMultipartRequestOptions parses a multipart request into the options of
+// its operations. The files are set as *Upload values at the variable paths
+// given by the map field. The returned flag is true if the operations field
+// holds a batch.
+func (h *Handler) newMultipartRequestOptions(r *fasthttp.Request) ([]*RequestOptions, bool, error) {
+	form, err := r.MultipartForm()
+	if err != nil {
+		return nil, false, &statusError{status: fasthttp.StatusBadRequest, message: "Invalid multipart body: " + err.Error()}
+	}
+	// files that aren't listed in the map field count as well, they have
+	// been received all the same
+	if files := countFiles(form); h.maxUploadFiles > 0 && files > h.maxUploadFiles {
+		return nil, false, &statusError{
+			status:  fasthttp.StatusRequestEntityTooLarge,
+			message: fmt.Sprintf("%d files exceed the maximum of %d.", files, h.maxUploadFiles),
+		}
+	}
+
+	operationsField := []byte(formValue(form, "operations"))
+	if err := h.limits.checkJSONDepth(operationsField); err != nil {
+		return nil, false, err
+	}
+	var operations interface{}
+	if err := json.Unmarshal(operationsField, &operations); err != nil {
+		return nil, false, &statusError{status: fasthttp.StatusBadRequest, message: "Invalid JSON in the operations multipart field."}
+	}
+	var fileMap map[string][]string
+	if err := json.Unmarshal([]byte(formValue(form, "map")), &fileMap); err != nil {
+		return nil, false, &statusError{status: fasthttp.StatusBadRequest, message: "Invalid JSON in the map multipart field."}
+	}
+
+	for name, paths := range fileMap {
+		files := form.File[name]
+		if len(files) == 0 {
+			return nil, false, &statusError{status: fasthttp.StatusBadRequest, message: fmt.Sprintf("File %q is missing in the request.", name)}
+		}
+		file := files[0]
+		if h.maxUploadSize > 0 && file.Size > h.maxUploadSize {
+			return nil, false, &statusError{
+				status:  fasthttp.StatusRequestEntityTooLarge,
+				message: fmt.Sprintf("File %q exceeds the maximum size of %d bytes.", name, h.maxUploadSize),
+			}
+		}
+		for _, path := range paths {
+			if !setPath(operations, strings.Split(path, "."), &Upload{FileHeader: file}) {
+				return nil, false, &statusError{status: fasthttp.StatusBadRequest, message: fmt.Sprintf("Invalid path %q for file %q.", path, name)}
+			}
+		}
+	}
+
+	switch operations := operations.(type) {
+	case map[string]interface{}:
+		return []*RequestOptions{requestOptionsFromMap(operations)}, false, nil
+	case []interface{}:
+		batch := make([]*RequestOptions, len(operations))
+		for i, operation := range operations {
+			operation, _ := operation.(map[string]interface{})
+			batch[i] = requestOptionsFromMap(operation)
+		}
+		return batch, true, nil
+	}
+	return nil, false, &statusError{status: fasthttp.StatusBadRequest, message: "The operations multipart field must hold an object or an array."}
+}
+
+// countFiles returns the number of file parts of form.
+func countFiles(form *multipart.Form) int {
+	count := 0
+	for _, files := range form.File {
+		count += len(files)
+	}
+	return count
+}
+
+func formValue(form *multipart.Form, name string) string {
+	if values := form.Value[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// setPath replaces the value at the given path of object paths below value
+// and returns false if there is no such value.
+func setPath(value interface{}, path []string, replacement interface{}) bool {
+	if len(path) == 0 {
+		return false
+	}
+	key, last := path[0], len(path) == 1
+	switch value := value.(type) {
+	case map[string]interface{}:
+		current, ok := value[key]
+		if !ok {
+			return false
+		}
+		if last {
+			value[key] = replacement
+			return true
+		}
+		return setPath(current, path[1:], replacement)
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(value) {
+			return false
+		}
+		if last {
+			value[index] = replacement
+			return true
+		}
+		return setPath(value[index], path[1:], replacement)
+	}
+	return false
+}
+
+// requestOptionsFromMap returns the options of an operation that has been
+// decoded into a map.
+func requestOptionsFromMap(operation map[string]interface{}) *RequestOptions {
+	opts := &RequestOptions{}
+	opts.Query, _ = operation["query"].(string)
+	opts.Variables, _ = operation["variables"].(map[string]interface{})
+	opts.OperationName, _ = operation["operationName"].(string)
+	opts.Extensions, _ = operation["extensions"].(map[string]interface{})
+	opts.DocumentID, _ = operation["documentId"].(string)
+	return opts
+}
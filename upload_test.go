@@ -0,0 +1,232 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/valyala/fasthttp"
+
+	handler "github.com/simia-tech/graphql-fasthttp-handler"
+)
+
+var uploadSchema = func() graphql.Schema {
+	readUpload := func(upload *handler.Upload) (string, error) {
+		file, err := upload.Open()
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		content, err := ioutil.ReadAll(file)
+		if err != nil {
+			return "", err
+		}
+		return upload.Filename + ":" + string(content), nil
+	}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{Type: graphql.String},
+			},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"upload": &graphql.Field{
+					Type: graphql.String,
+					Args: graphql.FieldConfigArgument{
+						"file": &graphql.ArgumentConfig{Type: graphql.NewNonNull(handler.UploadScalar)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return readUpload(p.Args["file"].(*handler.Upload))
+					},
+				},
+				"uploads": &graphql.Field{
+					Type: graphql.NewList(graphql.String),
+					Args: graphql.FieldConfigArgument{
+						"files": &graphql.ArgumentConfig{Type: graphql.NewList(handler.UploadScalar)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						results := []interface{}{}
+						for _, file := range p.Args["files"].([]interface{}) {
+							result, err := readUpload(file.(*handler.Upload))
+							if err != nil {
+								return nil, err
+							}
+							results = append(results, result)
+						}
+						return results, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}()
+
+func TestUploads(t *testing.T) {
+	single := `{"query": "mutation ($file: Upload!) { upload(file: $file) }", "variables": {"file": null}}`
+	multiple := `{"query": "mutation ($files: [Upload]) { uploads(files: $files) }", "variables": {"files": [null, null]}}`
+
+	testCases := map[string]struct {
+		config         handler.Config
+		operations     string
+		fileMap        string
+		files          map[string]string
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		"Single": {
+			operations:     single,
+			fileMap:        `{"0": ["variables.file"]}`,
+			files:          map[string]string{"0": "a"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]interface{}{"data": map[string]interface{}{"upload": "0.txt:a"}},
+		},
+		"List": {
+			operations:     multiple,
+			fileMap:        `{"0": ["variables.files.0"], "1": ["variables.files.1"]}`,
+			files:          map[string]string{"0": "a", "1": "b"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]interface{}{"data": map[string]interface{}{"uploads": []interface{}{"0.txt:a", "1.txt:b"}}},
+		},
+		"Batch": {
+			config:         handler.Config{Batching: true},
+			operations:     "[" + single + "," + single + "]",
+			fileMap:        `{"0": ["0.variables.file", "1.variables.file"]}`,
+			files:          map[string]string{"0": "a"},
+			expectedStatus: http.StatusOK,
+			expectedBody: []interface{}{
+				map[string]interface{}{"data": map[string]interface{}{"upload": "0.txt:a"}},
+				map[string]interface{}{"data": map[string]interface{}{"upload": "0.txt:a"}},
+			},
+		},
+		"BatchDisabled": {
+			operations:     "[" + single + "]",
+			fileMap:        `{"0": ["0.variables.file"]}`,
+			files:          map[string]string{"0": "a"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		"TooLarge": {
+			config:         handler.Config{MaxUploadSize: 2},
+			operations:     single,
+			fileMap:        `{"0": ["variables.file"]}`,
+			files:          map[string]string{"0": "abc"},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		"TooMany": {
+			config:         handler.Config{MaxUploadFiles: 1},
+			operations:     multiple,
+			fileMap:        `{"0": ["variables.files.0"], "1": ["variables.files.1"]}`,
+			files:          map[string]string{"0": "a", "1": "b"},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		"TooManyUnmapped": {
+			config:         handler.Config{MaxUploadFiles: 2},
+			operations:     single,
+			fileMap:        `{"0": ["variables.file"]}`,
+			files:          map[string]string{"0": "a", "1": "b", "2": "c"},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		"MissingFile": {
+			operations:     single,
+			fileMap:        `{"0": ["variables.file"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"InvalidPath": {
+			operations:     single,
+			fileMap:        `{"0": ["variables.unknown"]}`,
+			files:          map[string]string{"0": "a"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		"InvalidOperations": {
+			operations:     "{",
+			fileMap:        `{}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			config := testCase.config
+			config.Schema = &uploadSchema
+			config.Uploads = true
+			h := handler.New(&config)
+
+			req := newMultipartRequest(t, testCase.operations, testCase.fileMap, testCase.files)
+			defer fasthttp.ReleaseRequest(req)
+
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+
+			if err := serve(h.ServeHTTP, req, resp); err != nil {
+				t.Fatal(err)
+			}
+			if code := resp.StatusCode(); code != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatus, code, resp.Body())
+			}
+			if testCase.expectedBody == nil {
+				return
+			}
+			var body interface{}
+			if err := json.Unmarshal(resp.Body(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body, testCase.expectedBody) {
+				t.Fatalf("expected body %v, got %v", testCase.expectedBody, body)
+			}
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		h := handler.New(&handler.Config{
+			Schema: &uploadSchema,
+		})
+
+		req := newMultipartRequest(t, single, `{"0": ["variables.file"]}`, map[string]string{"0": "a"})
+		defer fasthttp.ReleaseRequest(req)
+
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+
+		if err := serve(h.ServeHTTP, req, resp); err != nil {
+			t.Fatal(err)
+		}
+		if code := resp.StatusCode(); code != http.StatusUnsupportedMediaType {
+			t.Fatalf("expected status %d, got %d", http.StatusUnsupportedMediaType, code)
+		}
+	})
+}
+
+func newMultipartRequest(t *testing.T, operations, fileMap string, files map[string]string) *fasthttp.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("operations", operations)
+	writer.WriteField("map", fileMap)
+	for name, content := range files {
+		part, err := writer.CreateFormFile(name, name+".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(content))
+	}
+	writer.Close()
+
+	req := fasthttp.AcquireRequest()
+	req.Header.SetHost("localhost")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType(writer.FormDataContentType())
+	req.URI().SetPath("/graphql")
+	req.SetBody(body.Bytes())
+	return req
+}
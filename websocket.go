@@ -0,0 +1,440 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// websocketMaxMessageSize limits messages unless MaxBodySize is set.
+	websocketMaxMessageSize = 1 << 20
+
+	websocketOpContinuation = 0x0
+	websocketOpText         = 0x1
+	websocketOpBinary       = 0x2
+	websocketOpClose        = 0x8
+	websocketOpPing         = 0x9
+	websocketOpPong         = 0xa
+
+	websocketCloseNormal        = 1000
+	websocketCloseProtocolError = 1002
+	websocketCloseTooBig        = 1009
+)
+
+// websocketCloseError is returned by websocketConn.ReadMessage once the peer
+// has closed the connection.
+type websocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *websocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+var (
+	errWebSocketProtocol = errors.New("websocket protocol error")
+	errWebSocketTooBig   = errors.New("websocket message too big")
+	errWebSocketClosed   = errors.New("websocket closed")
+)
+
+// isWebSocketUpgrade returns true if r asks for a WebSocket upgrade.
+func isWebSocketUpgrade(r *fasthttp.Request) bool {
+	return r.Header.IsGet() &&
+		headerHasToken(r.Header.Peek("Connection"), "upgrade") &&
+		headerHasToken(r.Header.Peek("Upgrade"), "websocket")
+}
+
+// headerHasToken reports whether the comma separated header value contains
+// token, compared case-insensitively.
+func headerHasToken(value []byte, token string) bool {
+	for _, part := range bytes.Split(value, []byte(",")) {
+		if bytes.EqualFold(bytes.TrimSpace(part), []byte(token)) {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket performs the server side of the opening handshake and
+// hands the connection over to handler. The first subprotocol offered by
+// the client that is contained in protocols gets selected. Messages larger
+// than maxMessageSize bytes close the connection. It returns false if the
+// handshake was rejected, in which case an error response has already been
+// written.
+func upgradeWebSocket(reqCtx *fasthttp.RequestCtx, protocols []string, maxMessageSize int, handler func(conn *websocketConn, protocol string)) bool {
+	if !bytes.Equal(reqCtx.Request.Header.Peek("Sec-WebSocket-Version"), []byte("13")) {
+		reqCtx.Response.Header.Set("Sec-WebSocket-Version", "13")
+		reqCtx.Error("unsupported websocket version", fasthttp.StatusUpgradeRequired)
+		return false
+	}
+	key := reqCtx.Request.Header.Peek("Sec-WebSocket-Key")
+	if len(key) == 0 {
+		reqCtx.Error("missing websocket key", fasthttp.StatusBadRequest)
+		return false
+	}
+	protocol := selectSubprotocol(reqCtx.Request.Header.Peek("Sec-WebSocket-Protocol"), protocols)
+	if protocol == "" {
+		reqCtx.Error("unsupported websocket subprotocol", fasthttp.StatusBadRequest)
+		return false
+	}
+
+	hash := sha1.New()
+	hash.Write(key)
+	hash.Write([]byte(websocketGUID))
+
+	reqCtx.SetStatusCode(fasthttp.StatusSwitchingProtocols)
+	reqCtx.Response.Header.Set("Upgrade", "websocket")
+	reqCtx.Response.Header.Set("Connection", "Upgrade")
+	reqCtx.Response.Header.Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(hash.Sum(nil)))
+	reqCtx.Response.Header.Set("Sec-WebSocket-Protocol", protocol)
+
+	reqCtx.Hijack(func(c net.Conn) {
+		handler(&websocketConn{
+			conn:           c,
+			reader:         bufio.NewReader(c),
+			maxMessageSize: maxMessageSize,
+		}, protocol)
+	})
+	return true
+}
+
+// selectSubprotocol returns the first protocol of the comma separated
+// offered list that is also supported, or an empty string if there is none.
+func selectSubprotocol(offered []byte, supported []string) string {
+	for _, part := range bytes.Split(offered, []byte(",")) {
+		part = bytes.TrimSpace(part)
+		for _, protocol := range supported {
+			if string(part) == protocol {
+				return protocol
+			}
+		}
+	}
+	return ""
+}
+
+// websocketConn implements the message layer of RFC 6455 on top of a
+// hijacked connection. Reads must happen from a single goroutine, writes
+// may happen concurrently.
+type websocketConn struct {
+	conn           net.Conn
+	reader         *bufio.Reader
+	maxMessageSize int
+	writeMu        sync.Mutex
+	closed         bool
+}
+
+// ReadMessage returns the next text or binary message. Control frames are
+// handled transparently.
+func (c *websocketConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			switch err {
+			case errWebSocketProtocol:
+				c.Close(websocketCloseProtocolError, "")
+			case errWebSocketTooBig:
+				c.Close(websocketCloseTooBig, "")
+			}
+			return nil, err
+		}
+
+		switch opcode {
+		case websocketOpPing:
+			if err := c.writeFrame(websocketOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case websocketOpPong:
+			continue
+		case websocketOpClose:
+			closeErr := &websocketCloseError{Code: websocketCloseNormal}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			c.Close(closeErr.Code, "")
+			return nil, closeErr
+		case websocketOpText, websocketOpBinary:
+			if started {
+				c.Close(websocketCloseProtocolError, "")
+				return nil, errWebSocketProtocol
+			}
+			started = true
+		case websocketOpContinuation:
+			if !started {
+				c.Close(websocketCloseProtocolError, "")
+				return nil, errWebSocketProtocol
+			}
+		default:
+			c.Close(websocketCloseProtocolError, "")
+			return nil, errWebSocketProtocol
+		}
+
+		if len(message)+len(payload) > c.maxMessageSize {
+			c.Close(websocketCloseTooBig, "")
+			return nil, errWebSocketTooBig
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// WriteMessage sends data as a single text frame.
+func (c *websocketConn) WriteMessage(data []byte) error {
+	return c.writeFrame(websocketOpText, data)
+}
+
+// Close sends a close frame with the given code and reason and closes the
+// underlying connection, which unblocks a pending ReadMessage.
+func (c *websocketConn) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	err := c.writeFrame(websocketOpClose, payload)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.conn.Close()
+	}
+	return err
+}
+
+func (c *websocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	if header[0]&0x70 != 0 || header[1]&0x80 == 0 {
+		// reserved bits must be zero and client frames must be masked
+		err = errWebSocketProtocol
+		return
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if opcode >= websocketOpClose && (length > 125 || !fin) {
+		err = errWebSocketProtocol
+		return
+	}
+	if length > uint64(c.maxMessageSize) {
+		err = errWebSocketTooBig
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+func (c *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|opcode)
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// websocketMessage is the envelope shared by the graphql-transport-ws and the
+// graphql-ws protocol.
+type websocketMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func writeWebSocketMessage(conn *websocketConn, id, messageType string, payload interface{}) error {
+	message := websocketMessage{ID: id, Type: messageType}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		message.Payload = data
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(data)
+}
+
+// decodeWebSocketPayload decodes the payload of a subscribe or start message
+// into opts, after checking it against the JSON depth limit.
+func (h *Handler) decodeWebSocketPayload(payload json.RawMessage, opts *RequestOptions) error {
+	if err := h.limits.checkJSONDepth(payload); err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, opts)
+}
+
+// serveWebSocket upgrades the request and serves GraphQL operations over the
+// resulting connection using the negotiated subprotocol.
+func (h *Handler) serveWebSocket(reqCtx *fasthttp.RequestCtx) {
+	if !h.allowsWebSocketOrigin(&reqCtx.Request) {
+		reqCtx.Error("websocket origin not allowed", fasthttp.StatusForbidden)
+		return
+	}
+
+	var root map[string]interface{}
+	if h.rootObjectFn != nil {
+		root = h.rootObjectFn(reqCtx)
+	}
+	ctx := detachedContext(reqCtx)
+
+	maxMessageSize := websocketMaxMessageSize
+	if h.limits.maxBodySize > 0 {
+		maxMessageSize = h.limits.maxBodySize
+	}
+
+	protocols := []string{protocolGraphQLTransportWS, protocolGraphQLWS}
+	upgradeWebSocket(reqCtx, protocols, maxMessageSize, func(conn *websocketConn, protocol string) {
+		switch protocol {
+		case protocolGraphQLWS:
+			h.serveGraphQLWS(ctx, conn, root)
+		default:
+			h.serveGraphQLTransportWS(ctx, conn, root)
+		}
+	})
+}
+
+// allowsWebSocketOrigin returns true if the WebSocket upgrade r may be
+// accepted. Requests without Origin header don't come from browsers.
+func (h *Handler) allowsWebSocketOrigin(r *fasthttp.Request) bool {
+	if h.checkOrigin != nil {
+		return h.checkOrigin(r)
+	}
+	origin := string(r.Header.Peek("Origin"))
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, string(r.Host())) {
+		return true
+	}
+	return h.cors != nil && h.cors.allowsOrigin(origin)
+}
+
+// websocketOperations tracks the running operations of a connection by id.
+type websocketOperations struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	running map[string]*websocketOperation
+}
+
+type websocketOperation struct {
+	cancel context.CancelFunc
+}
+
+// start runs fn in a new goroutine under id. It returns false if an
+// operation with the same id is already running.
+func (o *websocketOperations) start(ctx context.Context, id string, fn func(ctx context.Context)) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, exists := o.running[id]; exists {
+		return false
+	}
+	if o.running == nil {
+		o.running = map[string]*websocketOperation{}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	operation := &websocketOperation{cancel: cancel}
+	o.running[id] = operation
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		defer cancel()
+		fn(ctx)
+
+		o.mu.Lock()
+		if o.running[id] == operation {
+			delete(o.running, id)
+		}
+		o.mu.Unlock()
+	}()
+	return true
+}
+
+// stop cancels the operation running under id.
+func (o *websocketOperations) stop(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if operation, ok := o.running[id]; ok {
+		operation.cancel()
+		delete(o.running, id)
+	}
+}
+
+// stopAll cancels all running operations and waits for them to return.
+func (o *websocketOperations) stopAll() {
+	o.mu.Lock()
+	for id, operation := range o.running {
+		operation.cancel()
+		delete(o.running, id)
+	}
+	o.mu.Unlock()
+	o.wg.Wait()
+}